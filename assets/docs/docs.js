@@ -0,0 +1,188 @@
+// Renders the API reference from the OpenAPI document served by the app.
+// Kept dependency free so that the docs page works without internet.
+(function () {
+  "use strict";
+
+  var methods = ["get", "put", "post", "delete", "options", "head", "patch", "trace"];
+  var maxDepth = 6;
+
+  function esc(s) {
+    return String(s === undefined || s === null ? "" : s)
+      .replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;")
+      .replace(/"/g, "&quot;").replace(/'/g, "&#39;");
+  }
+
+  function el(tag, cls, html) {
+    var e = document.createElement(tag);
+    if (cls) e.className = cls;
+    if (html !== undefined) e.innerHTML = html;
+    return e;
+  }
+
+  function refName(ref) {
+    return ref.substring(ref.lastIndexOf("/") + 1);
+  }
+
+  function resolve(doc, schema) {
+    if (schema && schema.$ref) {
+      var name = refName(schema.$ref);
+      return { name: name, schema: (doc.components.schemas || {})[name] || {} };
+    }
+    return { name: "", schema: schema || {} };
+  }
+
+  function typeLabel(doc, schema) {
+    if (!schema) return "any";
+    if (schema.$ref) return refName(schema.$ref);
+    if (schema.type === "array") return "[]" + typeLabel(doc, schema.items);
+    if (schema.type === "object" && schema.additionalProperties) {
+      return "map[string]" + typeLabel(doc, schema.additionalProperties);
+    }
+    var t = schema.type || "any";
+    return schema.format ? t + " (" + schema.format + ")" : t;
+  }
+
+  function constraints(schema) {
+    var res = [];
+    if (schema.enum) res.push("one of: " + schema.enum.map(esc).join(", "));
+    if (schema.minimum !== undefined) res.push(">= " + schema.minimum);
+    if (schema.maximum !== undefined) res.push("<= " + schema.maximum);
+    if (schema.minLength !== undefined) res.push("min length " + schema.minLength);
+    if (schema.maxLength !== undefined) res.push("max length " + schema.maxLength);
+    if (schema.minItems !== undefined) res.push("min items " + schema.minItems);
+    if (schema.maxItems !== undefined) res.push("max items " + schema.maxItems);
+    return res;
+  }
+
+  // renderSchema renders the properties of the (resolved) object schema.
+  // seen guards against the recursive types.
+  function renderSchema(doc, schema, depth, seen) {
+    var r = resolve(doc, schema);
+    var s = r.schema;
+    if (s.type === "array" && s.items) return renderSchema(doc, s.items, depth, seen);
+    if (s.type === "object" && s.additionalProperties) {
+      return renderSchema(doc, s.additionalProperties, depth, seen);
+    }
+    if (!s.properties || depth > maxDepth || (r.name && seen[r.name])) return null;
+
+    var next = Object.assign({}, seen);
+    if (r.name) next[r.name] = true;
+
+    var required = {};
+    (s.required || []).forEach(function (n) { required[n] = true; });
+
+    var table = el("table", "schema");
+    Object.keys(s.properties).sort().forEach(function (name) {
+      var prop = s.properties[name];
+      var target = resolve(doc, prop).schema;
+      var desc = prop.description || target.description || "";
+      var notes = constraints(target);
+
+      var row = el("tr");
+      row.appendChild(el("td", "name", esc(name) + (required[name] ? ' <span class="req">required</span>' : "")));
+      var cell = el("td", "", '<span class="type">' + esc(typeLabel(doc, prop)) + "</span>" +
+        (desc ? '<div class="desc">' + esc(desc) + "</div>" : "") +
+        (notes.length ? '<div class="notes">' + esc(notes.join("; ")) + "</div>" : ""));
+      var nested = renderSchema(doc, prop, depth + 1, next);
+      if (nested) cell.appendChild(nested);
+      row.appendChild(cell);
+      table.appendChild(row);
+    });
+    return table;
+  }
+
+  function renderBody(doc, title, content) {
+    var sec = el("div", "section");
+    Object.keys(content || {}).forEach(function (mediaType) {
+      var media = content[mediaType];
+      sec.appendChild(el("h4", "", esc(title) + ' <span class="media">' + esc(mediaType) + "</span> " +
+        '<span class="type">' + esc(typeLabel(doc, media.schema)) + "</span>"));
+      var table = renderSchema(doc, media.schema, 0, {});
+      if (table) sec.appendChild(table);
+    });
+    return sec;
+  }
+
+  function renderOperation(doc, path, method, op) {
+    var id = op.operationId || method + path;
+    var card = el("section", "operation" + (op.deprecated ? " deprecated" : ""));
+    card.id = id;
+    card.appendChild(el("h3", "", '<span class="method ' + method + '">' + method.toUpperCase() +
+      '</span> <code>' + esc(path) + "</code>"));
+    if (op.summary) card.appendChild(el("p", "summary", esc(op.summary)));
+    if (op.description) card.appendChild(el("p", "desc", esc(op.description)));
+
+    if (op.parameters && op.parameters.length) {
+      var sec = el("div", "section");
+      sec.appendChild(el("h4", "", "Parameters"));
+      var table = el("table", "schema");
+      op.parameters.forEach(function (p) {
+        var notes = constraints(resolve(doc, p.schema).schema);
+        table.appendChild(el("tr", "", '<td class="name">' + esc(p.name) +
+          (p.required ? ' <span class="req">required</span>' : "") + "</td>" +
+          '<td><span class="in">' + esc(p.in) + '</span> <span class="type">' +
+          esc(typeLabel(doc, p.schema)) + "</span>" +
+          (notes.length ? '<div class="notes">' + esc(notes.join("; ")) + "</div>" : "") + "</td>"));
+      });
+      sec.appendChild(table);
+      card.appendChild(sec);
+    }
+
+    if (op.requestBody) card.appendChild(renderBody(doc, "Request body", op.requestBody.content));
+
+    Object.keys(op.responses || {}).sort().forEach(function (status) {
+      var resp = op.responses[status];
+      var cls = status.charAt(0) === "2" ? "ok" : "err";
+      var sec = el("div", "section");
+      sec.appendChild(el("h4", "", '<span class="status ' + cls + '">' + esc(status) + "</span> " +
+        esc(resp.description)));
+      if (resp.content) sec.appendChild(renderBody(doc, "Response", resp.content));
+      card.appendChild(sec);
+    });
+    return card;
+  }
+
+  function render(root, doc) {
+    doc.components = doc.components || {};
+    document.title = (doc.info.title || "API") + " - API Reference";
+
+    var groups = {};
+    Object.keys(doc.paths || {}).sort().forEach(function (path) {
+      var item = doc.paths[path];
+      methods.forEach(function (m) {
+        if (!item[m]) return;
+        var tag = (item[m].tags && item[m].tags[0]) || "default";
+        (groups[tag] = groups[tag] || []).push({ path: path, method: m, op: item[m] });
+      });
+    });
+
+    var nav = el("nav");
+    var main = el("main");
+    main.appendChild(el("h1", "", esc(doc.info.title) + ' <span class="version">' + esc(doc.info.version) + "</span>"));
+    if (doc.info.description) main.appendChild(el("p", "desc", esc(doc.info.description)));
+
+    Object.keys(groups).sort().forEach(function (tag) {
+      nav.appendChild(el("h5", "", esc(tag)));
+      main.appendChild(el("h2", "", esc(tag)));
+      groups[tag].forEach(function (o) {
+        var card = renderOperation(doc, o.path, o.method, o.op);
+        main.appendChild(card);
+        nav.appendChild(el("a", "", '<span class="method ' + o.method + '">' + o.method.toUpperCase() +
+          "</span> " + esc(o.op.summary || o.path))).href = "#" + card.id;
+      });
+    });
+
+    root.innerHTML = "";
+    root.appendChild(nav);
+    root.appendChild(main);
+  }
+
+  var root = document.getElementById("docs");
+  fetch(root.getAttribute("data-spec-url"), { headers: { Accept: "application/json" } })
+    .then(function (resp) {
+      if (!resp.ok) throw new Error("failed to load the document (status " + resp.status + ")");
+      return resp.json();
+    })
+    .then(function (doc) { render(root, doc); })
+    .catch(function (err) { root.innerHTML = '<p class="error">' + esc(err.message) + "</p>"; });
+})();
@@ -78,6 +78,11 @@ func (l *viperLoader) load() error {
 		_ = v.ReadInConfig()
 	}
 
+	// viper holds the defaults of all the keys now. So the values are
+	// decoded into a zero struct, otherwise slices from the configs are
+	// merged into the default slices by index.
+	rv := reflect.ValueOf(l.intoPtr).Elem()
+	rv.Set(reflect.Zero(rv.Type()))
 	return v.Unmarshal(l.intoPtr)
 }
 
@@ -110,6 +115,9 @@ func readRecursive(rv reflect.Value, rootKey string) ([]configDef, error) {
 		fv := deref(rv.Field(i))
 
 		key := toCamelCase(ft.Name)
+		if tag := strings.Split(ft.Tag.Get("mapstructure"), ",")[0]; tag != "" && tag != "-" {
+			key = tag
+		}
 		if rootKey != "" {
 			key = fmt.Sprintf("%s.%s", rootKey, key)
 		}
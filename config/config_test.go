@@ -0,0 +1,97 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/config"
+)
+
+type testConfig struct {
+	Addr        string   `default:":8080"`
+	Middlewares []string `mapstructure:"middlewares" default:"[request_id,access_log,recover]"`
+	TLS         struct {
+		CertFile string `mapstructure:"cert_file"`
+		KeyFile  string `mapstructure:"key_file" default:"server.key"`
+	} `mapstructure:"tls"`
+}
+
+func TestLoad(t *testing.T) {
+	table := []struct {
+		title string
+		file  string
+		env   map[string]string
+		want  func(cfg *testConfig)
+	}{
+		{
+			title: "Defaults",
+			want: func(cfg *testConfig) {
+				cfg.Addr = ":8080"
+				cfg.Middlewares = []string{"request_id", "access_log", "recover"}
+				cfg.TLS.KeyFile = "server.key"
+			},
+		},
+		{
+			title: "SliceReplacesDefault",
+			file:  "middlewares: [recover]\naddr: :9090\n",
+			want: func(cfg *testConfig) {
+				cfg.Addr = ":9090"
+				cfg.Middlewares = []string{"recover"}
+				cfg.TLS.KeyFile = "server.key"
+			},
+		},
+		{
+			title: "EmptySliceKept",
+			file:  "middlewares: []\n",
+			want: func(cfg *testConfig) {
+				cfg.Addr = ":8080"
+				cfg.Middlewares = []string{}
+				cfg.TLS.KeyFile = "server.key"
+			},
+		},
+		{
+			title: "TaggedKeysFromFile",
+			file:  "tls:\n  cert_file: server.crt\n",
+			want: func(cfg *testConfig) {
+				cfg.Addr = ":8080"
+				cfg.Middlewares = []string{"request_id", "access_log", "recover"}
+				cfg.TLS.CertFile = "server.crt"
+				cfg.TLS.KeyFile = "server.key"
+			},
+		},
+		{
+			title: "TaggedKeysFromEnv",
+			env:   map[string]string{"TESTAPP_TLS_CERT_FILE": "env.crt", "TESTAPP_ADDR": ":7070"},
+			want: func(cfg *testConfig) {
+				cfg.Addr = ":7070"
+				cfg.Middlewares = []string{"request_id", "access_log", "recover"}
+				cfg.TLS.CertFile = "env.crt"
+				cfg.TLS.KeyFile = "server.key"
+			},
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.title, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			opts := []config.Option{config.WithEnv("testapp"), config.WithName("testapp-nonexistent")}
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yml")
+				require.NoError(t, os.WriteFile(path, []byte(tt.file), 0o600))
+				opts = append(opts, config.WithFile(path))
+			}
+
+			var got, want testConfig
+			require.NoError(t, config.Load(&got, opts...))
+			tt.want(&want)
+			assert.Equal(t, want, got)
+		})
+	}
+}
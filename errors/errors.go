@@ -1,9 +1,13 @@
 package errors
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
 	"strings"
+	"syscall"
+	"time"
 )
 
 // Common timer domain errors. Use `ErrX.WithCausef()` to clone and add context.
@@ -14,12 +18,26 @@ var (
 	ErrForbidden   = Error{Code: "forbidden", Message: "You are not authorised for the requested action"}
 	ErrInternal    = Error{Code: "internal_error", Message: "Some unexpected error occurred"}
 	ErrUnsupported = Error{Code: "unsupported", Message: "Requested feature is not supported"}
+	ErrUnavailable = Error{Code: "unavailable", Message: "Service is temporarily unavailable"}
+	ErrTimeout     = Error{Code: "timeout", Message: "Request timed out"}
+	ErrRateLimited = Error{Code: "rate_limited", Message: "Too many requests, please try again later"}
+	ErrTooLarge    = Error{Code: "too_large", Message: "Request entity is too large"}
 )
 
-// E converts any given error to the Error type. Unknown are converted
-// to ErrInternal.
+// retryableCodes is the set of error codes that are considered retryable
+// unless overridden on the error instance.
+var retryableCodes = map[string]bool{
+	ErrUnavailable.Code: true,
+	ErrTimeout.Code:     true,
+	ErrRateLimited.Code: true,
+}
+
+// E converts any given error to the Error type. Error wrapped in err (e.g.,
+// using fmt.Errorf("%w")) is returned as is. Unknown are converted to
+// ErrInternal.
 func E(err error) Error {
-	if e, ok := err.(Error); ok {
+	var e Error
+	if errors.As(err, &e) {
 		return e
 	}
 	return ErrInternal.WithCausef(err.Error())
@@ -28,11 +46,31 @@ func E(err error) Error {
 // Error represents any error returned by the components along with any
 // relevant context.
 type Error struct {
-	Code    string `json:"code"`
-	Cause   string `json:"cause,omitempty"`
-	Message string `json:"message"`
+	Code    string `json:"code" xml:"code"`
+	Cause   string `json:"cause,omitempty" xml:"cause,omitempty"`
+	Message string `json:"message" xml:"message"`
+
+	retry      retryMode
+	retryAfter time.Duration
+
+	// fields is a pointer to keep Error comparable.
+	fields *[]FieldError
+}
+
+// FieldError describes why a specific field of the request is invalid.
+type FieldError struct {
+	Field  string `json:"field" xml:"name,attr"`
+	Reason string `json:"reason" xml:"reason,attr"`
 }
 
+type retryMode int8
+
+const (
+	retryDefault retryMode = iota
+	retryAlways
+	retryNever
+)
+
 // WithCausef returns clone of err with the cause added. Use this when
 // you need to provide description of the underlying technical root-cause
 // which may be written in log for debugging purposes. Cause will be shown
@@ -53,6 +91,65 @@ func (err Error) WithMsgf(format string, args ...interface{}) Error {
 	return cloned
 }
 
+// WithRetryable returns a clone of the error with the retryable flag
+// overridden. Use this when the retry semantics of a specific instance
+// differ from the default for its code.
+func (err Error) WithRetryable(retryable bool) Error {
+	cloned := err
+	cloned.retry = retryNever
+	if retryable {
+		cloned.retry = retryAlways
+	}
+	return cloned
+}
+
+// WithRetryAfter returns a clone of the error marked as retryable with
+// the suggested delay before the client should retry.
+func (err Error) WithRetryAfter(d time.Duration) Error {
+	cloned := err.WithRetryable(true)
+	cloned.retryAfter = d
+	return cloned
+}
+
+// Retryable returns true if the operation that resulted in this error
+// may succeed when retried. Unless overridden using WithRetryable() or
+// WithRetryAfter(), this is derived from the error code.
+func (err Error) Retryable() bool {
+	switch err.retry {
+	case retryAlways:
+		return true
+	case retryNever:
+		return false
+	default:
+		return retryableCodes[err.Code]
+	}
+}
+
+// Temporary is an alias for Retryable() that satisfies the conventional
+// `interface{ Temporary() bool }` used by the net package.
+func (err Error) Temporary() bool { return err.Retryable() }
+
+// RetryAfter returns the suggested delay before retrying. Zero value
+// indicates no specific suggestion.
+func (err Error) RetryAfter() time.Duration { return err.retryAfter }
+
+// WithFields returns a clone of the error with the field-level details
+// added. Use this with ErrInvalid to report validation failures.
+func (err Error) WithFields(fields ...FieldError) Error {
+	merged := append(err.Fields(), fields...)
+	cloned := err
+	cloned.fields = &merged
+	return cloned
+}
+
+// Fields returns the field-level details of the error, if any.
+func (err Error) Fields() []FieldError {
+	if err.fields == nil {
+		return nil
+	}
+	return append([]FieldError(nil), *err.fields...)
+}
+
 // Is checks if 'other' is of type Error and has the same code.
 // See https://blog.golang.org/go1.13-errors.
 func (err Error) Is(other error) bool {
@@ -85,3 +182,39 @@ func Is(err, target error) bool { return errors.Is(err, target) }
 // New returns a new error equivalent to ErrInternal.
 // This function is a convenience shortcut for the errors.New().
 func New(msg string) error { return errors.New(msg) }
+
+// IsRetryable returns true if 'err' indicates a transient failure that
+// may succeed when retried. Error values are checked using Retryable().
+// Context deadlines, network timeouts and temporary network errors are
+// considered retryable while context cancellation is not.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var e Error
+	if errors.As(err, &e) {
+		return e.Retryable()
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return false
+
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET):
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var tempErr interface{ Temporary() bool }
+	if errors.As(err, &tempErr) {
+		return tempErr.Temporary()
+	}
+	return false
+}
@@ -1,8 +1,13 @@
 package errors_test
 
 import (
+	"context"
 	goerrors "errors"
+	"fmt"
+	"net"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -147,3 +152,159 @@ func Test_Errorf(t *testing.T) {
 	assert.Error(t, e)
 	assert.EqualError(t, e, "failed: 100")
 }
+
+func Test_E(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		title string
+		err   error
+		want  errors.Error
+	}{
+		{
+			title: "Error",
+			err:   errors.ErrNotFound,
+			want:  errors.ErrNotFound,
+		},
+		{
+			title: "Wrapped",
+			err:   fmt.Errorf("loading user: %w", errors.ErrRateLimited.WithRetryAfter(time.Minute)),
+			want:  errors.ErrRateLimited.WithRetryAfter(time.Minute),
+		},
+		{
+			title: "Unknown",
+			err:   goerrors.New("boom"),
+			want:  errors.ErrInternal.WithCausef("boom"),
+		},
+	}
+
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.title, func(t *testing.T) {
+			got := errors.E(tt.err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want.RetryAfter(), got.RetryAfter())
+		})
+	}
+}
+
+func TestError_Retryable(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		title      string
+		err        errors.Error
+		want       bool
+		retryAfter time.Duration
+	}{
+		{
+			title: "NonRetryableCode",
+			err:   errors.ErrInvalid,
+			want:  false,
+		},
+		{
+			title: "RetryableCode",
+			err:   errors.ErrUnavailable.WithCausef("db down"),
+			want:  true,
+		},
+		{
+			title: "OverriddenToRetryable",
+			err:   errors.ErrInternal.WithRetryable(true),
+			want:  true,
+		},
+		{
+			title: "OverriddenToNonRetryable",
+			err:   errors.ErrTimeout.WithRetryable(false),
+			want:  false,
+		},
+		{
+			title:      "WithRetryAfter",
+			err:        errors.ErrConflict.WithRetryAfter(3 * time.Second),
+			want:       true,
+			retryAfter: 3 * time.Second,
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.title, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.err.Retryable())
+			assert.Equal(t, tt.want, tt.err.Temporary())
+			assert.Equal(t, tt.retryAfter, tt.err.RetryAfter())
+			assert.True(t, goerrors.Is(tt.err, tt.err.WithRetryable(!tt.want)))
+		})
+	}
+}
+
+func Test_IsRetryable(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		title string
+		err   error
+		want  bool
+	}{
+		{
+			title: "Nil",
+			err:   nil,
+			want:  false,
+		},
+		{
+			title: "UnknownError",
+			err:   goerrors.New("foo"),
+			want:  false,
+		},
+		{
+			title: "WrappedError",
+			err:   fmt.Errorf("failed: %w", errors.ErrTimeout),
+			want:  true,
+		},
+		{
+			title: "ContextCancelled",
+			err:   context.Canceled,
+			want:  false,
+		},
+		{
+			title: "ContextDeadline",
+			err:   fmt.Errorf("query: %w", context.DeadlineExceeded),
+			want:  true,
+		},
+		{
+			title: "NetTimeout",
+			err:   &net.DNSError{IsTimeout: true},
+			want:  true,
+		},
+		{
+			title: "NetTemporary",
+			err:   &net.DNSError{IsTemporary: true},
+			want:  true,
+		},
+		{
+			title: "ConnRefused",
+			err:   &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED},
+			want:  true,
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.title, func(t *testing.T) {
+			assert.Equal(t, tt.want, errors.IsRetryable(tt.err))
+		})
+	}
+}
+
+func TestError_WithFields(t *testing.T) {
+	base := errors.ErrInvalid.WithFields(errors.FieldError{Field: "name", Reason: "is required"})
+	more := base.WithFields(errors.FieldError{Field: "age", Reason: "must be positive"})
+
+	assert.Len(t, base.Fields(), 1)
+	assert.Equal(t, []errors.FieldError{
+		{Field: "name", Reason: "is required"},
+		{Field: "age", Reason: "must be positive"},
+	}, more.Fields())
+	assert.Nil(t, errors.ErrInvalid.Fields())
+	assert.True(t, errors.Is(more, errors.ErrInvalid))
+
+	// Error must remain comparable.
+	var err error = more
+	assert.NotPanics(t, func() { _ = err == error(errors.ErrInvalid) })
+}
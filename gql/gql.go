@@ -0,0 +1,152 @@
+// Package gql serves gqlgen executable schemas with the moonshot error
+// conventions, query complexity limits, persisted queries and
+// subscriptions over websocket.
+package gql
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gorilla/websocket"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/httputils"
+	"github.com/spy16/moonshot/log"
+)
+
+// Options controls the behaviour of the GraphQL handler.
+type Options struct {
+	// ComplexityLimit is the maximum complexity allowed for a query.
+	// Zero or negative disables the limit.
+	ComplexityLimit int
+
+	// PersistedQueries is the size of the automatic persisted queries
+	// cache. Zero or negative disables persisted queries.
+	PersistedQueries int
+
+	// DisableIntrospection disables the schema introspection queries.
+	DisableIntrospection bool
+
+	// KeepAlive is the interval of keepalive messages sent on the
+	// subscription websockets. Defaults to 10s.
+	KeepAlive time.Duration
+
+	// CheckOrigin returns true if the origin of the websocket request is
+	// allowed (see httputils.CheckOrigin). Defaults to allowing only the
+	// same origin.
+	CheckOrigin func(req *http.Request) bool
+
+	// InitFunc is invoked with the websocket connection init payload (e.g.,
+	// to authenticate using a token in the payload).
+	InitFunc transport.WebsocketInitFunc
+
+	// PanicReporter is invoked when a resolver panics.
+	PanicReporter httputils.PanicReporter
+}
+
+// Handler returns an http.Handler that serves the schema over GET, POST,
+// multipart form (uploads) and websocket (subscriptions). Subscription
+// websockets are closed when the server started using GracefulServe
+// shuts down.
+func Handler(schema graphql.ExecutableSchema, opts Options) http.Handler {
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = 10 * time.Second
+	}
+
+	srv := handler.New(schema)
+
+	// websocket transport must be added first since others support the
+	// GET requests used for the upgrade.
+	srv.AddTransport(transport.Websocket{
+		Upgrader:              websocket.Upgrader{CheckOrigin: opts.CheckOrigin},
+		InitFunc:              opts.InitFunc,
+		KeepAlivePingInterval: opts.KeepAlive,
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{})
+
+	srv.SetQueryCache(lru.New(1000))
+	if !opts.DisableIntrospection {
+		srv.Use(extension.Introspection{})
+	}
+	if opts.PersistedQueries > 0 {
+		srv.Use(extension.AutomaticPersistedQuery{Cache: lru.New(opts.PersistedQueries)})
+	}
+	if opts.ComplexityLimit > 0 {
+		srv.Use(extension.FixedComplexityLimit(opts.ComplexityLimit))
+	}
+
+	srv.SetErrorPresenter(ErrorPresenter)
+	srv.SetRecoverFunc(func(ctx context.Context, rec interface{}) error {
+		stack := debug.Stack()
+		log.Error(ctx, "recovered from panic in resolver", "panic", rec, "stack", string(stack))
+		if opts.PanicReporter != nil {
+			httputils.ReportPanic(ctx, opts.PanicReporter, rec, stack)
+		}
+		return errors.ErrInternal
+	})
+
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if shutdown := httputils.ShutdownSignal(req.Context()); shutdown != nil && websocket.IsWebSocketUpgrade(req) {
+			// subscriptions are terminated when the request context ends.
+			ctx, cancel := context.WithCancel(req.Context())
+			defer cancel()
+			go func() {
+				select {
+				case <-shutdown:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+			req = req.WithContext(ctx)
+		}
+		srv.ServeHTTP(wr, req)
+	})
+}
+
+// ErrorPresenter presents errors.Error (and other errors returned by the
+// resolvers) similar to the HTTP error responses: message is used as is
+// and the code, cause, field details and request ID are added to the
+// extensions. Errors from GraphQL parsing/validation are left as is.
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+	var e errors.Error
+	if !stderrors.As(err, &e) {
+		if gqlErr.Unwrap() == nil {
+			return gqlErr
+		}
+		e = errors.E(gqlErr.Unwrap())
+	}
+
+	gqlErr.Message = e.Message
+	if gqlErr.Message == "" {
+		gqlErr.Message = e.Error()
+	}
+
+	if gqlErr.Extensions == nil {
+		gqlErr.Extensions = map[string]interface{}{}
+	}
+	gqlErr.Extensions["code"] = e.Code
+	if e.Cause != "" {
+		gqlErr.Extensions["cause"] = e.Cause
+	}
+	if fields := e.Fields(); len(fields) > 0 {
+		gqlErr.Extensions["fields"] = fields
+	}
+	if reqID := httputils.RequestIDFrom(ctx); reqID != "" {
+		gqlErr.Extensions["request_id"] = reqID
+	}
+	return gqlErr
+}
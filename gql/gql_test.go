@@ -0,0 +1,233 @@
+package gql_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/gql"
+	"github.com/spy16/moonshot/httputils"
+	"github.com/spy16/moonshot/log/logtest"
+)
+
+var testSchema = gqlparser.MustLoadSchema(&ast.Source{Input: `
+type Query { hello: String  fail: String  crash: String  bug: String }
+type Subscription { ticks: Int }
+`})
+
+// fakeSchema resolves the root fields of the test schema. Subscription
+// sends 'ticks' responses and then waits for the context to end.
+func fakeSchema(ticks int) graphql.ExecutableSchema {
+	return &graphql.ExecutableSchemaMock{
+		SchemaFunc: func() *ast.Schema { return testSchema },
+		ComplexityFunc: func(typeName, fieldName string, childComplexity int, args map[string]interface{}) (int, bool) {
+			return 0, false
+		},
+		ExecFunc: func(ctx context.Context) graphql.ResponseHandler {
+			oc := graphql.GetOperationContext(ctx)
+			if oc.Operation.Operation == ast.Subscription {
+				sent := 0
+				return func(ctx context.Context) *graphql.Response {
+					if sent == ticks {
+						<-ctx.Done()
+						return nil
+					}
+					sent++
+					return &graphql.Response{Data: json.RawMessage(`{"ticks":` + string(rune('0'+sent)) + `}`)}
+				}
+			}
+
+			return graphql.OneShot(resolveQuery(ctx, oc))
+		},
+	}
+}
+
+func resolveQuery(ctx context.Context, oc *graphql.OperationContext) (res *graphql.Response) {
+	data := map[string]interface{}{}
+	for _, f := range graphql.CollectFields(oc, oc.Operation.SelectionSet, nil) {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					graphql.AddError(ctx, oc.Recover(ctx, r))
+				}
+			}()
+
+			switch f.Name {
+			case "hello":
+				data[f.Alias] = "world"
+
+			case "fail":
+				data[f.Alias] = nil
+				graphql.AddError(ctx, errors.ErrNotFound.WithCausef("no such greeting"))
+
+			case "bug":
+				data[f.Alias] = nil
+				graphql.AddError(ctx, context.DeadlineExceeded)
+
+			case "crash":
+				data[f.Alias] = nil
+				panic("boom")
+			}
+		}()
+	}
+	raw, _ := json.Marshal(data)
+	return &graphql.Response{Data: raw, Errors: graphql.GetErrors(ctx)}
+}
+
+func TestHandler_Query(t *testing.T) {
+	t.Parallel()
+
+	var reported interface{}
+	h := gql.Handler(fakeSchema(0), gql.Options{
+		ComplexityLimit:  3,
+		PersistedQueries: 10,
+		PanicReporter:    func(ctx context.Context, rec interface{}, stack []byte) { reported = rec },
+	})
+
+	table := []struct {
+		title string
+		body  string
+		want  string
+	}{
+		{
+			title: "Success",
+			body:  `{"query": "{ hello }"}`,
+			want:  `{"data":{"hello":"world"}}`,
+		},
+		{
+			title: "Error",
+			body:  `{"query": "{ fail }"}`,
+			want:  `{"errors":[{"message":"Requested entity not found","extensions":{"cause":"no such greeting","code":"not_found","request_id":"req-1"}}],"data":null}`,
+		},
+		{
+			title: "UnknownError",
+			body:  `{"query": "{ bug }"}`,
+			want:  `{"errors":[{"message":"Some unexpected error occurred","extensions":{"cause":"context deadline exceeded","code":"internal_error","request_id":"req-1"}}],"data":null}`,
+		},
+		{
+			title: "Panic",
+			body:  `{"query": "{ crash }"}`,
+			want:  `{"errors":[{"message":"Some unexpected error occurred","extensions":{"code":"internal_error","request_id":"req-1"}}],"data":null}`,
+		},
+		{
+			title: "ComplexityLimit",
+			body:  `{"query": "{ a: hello b: hello c: hello d: hello }"}`,
+			want:  `{"errors":[{"message":"operation has complexity 4, which exceeds the limit of 3","extensions":{"code":"COMPLEXITY_LIMIT_EXCEEDED"}}],"data":null}`,
+		},
+		{
+			title: "PersistedQueryNotFound",
+			body:  `{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": "abc"}}}`,
+			want:  `{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}],"data":null}`,
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.title, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			ctx, logs := logtest.Capture(t)
+			req = req.WithContext(httputils.WithRequestID(ctx, "req-1"))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+			assert.JSONEq(t, tt.want, rec.Body.String())
+			if tt.title == "Panic" {
+				require.Len(t, logs.Entries(), 1)
+				assert.Equal(t, "recovered from panic in resolver", logs.Entries()[0].Message)
+			}
+		})
+	}
+	assert.Equal(t, "boom", reported)
+}
+
+func TestHandler_ReporterPanic(t *testing.T) {
+	t.Parallel()
+
+	h := gql.Handler(fakeSchema(0), gql.Options{
+		PanicReporter: func(ctx context.Context, rec interface{}, stack []byte) { panic("reporter is broken") },
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "{ crash }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	ctx, logs := logtest.Capture(t)
+	req = req.WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+	assert.JSONEq(t, `{"errors":[{"message":"Some unexpected error occurred","extensions":{"code":"internal_error"}}],"data":null}`, rec.Body.String())
+	require.Len(t, logs.Entries(), 2)
+	assert.Equal(t, "panic reporter panicked", logs.Entries()[1].Message)
+}
+
+func TestHandler_Subscription(t *testing.T) {
+	t.Parallel()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- httputils.GracefulServe(ctx, 10*time.Second, lis, gql.Handler(fakeSchema(2), gql.Options{}))
+	}()
+
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	var conn *websocket.Conn
+	require.Eventually(t, func() bool {
+		conn, _, err = dialer.Dial("ws://"+addr, nil)
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"type": "connection_init"}))
+	assert.Equal(t, `{"type":"connection_ack"}`, readMsg(t, conn))
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"id": "1", "type": "subscribe", "payload": map[string]string{"query": "subscription { ticks }"},
+	}))
+	assert.Equal(t, `{"id":"1","payload":{"data":{"ticks":1}},"type":"next"}`, readMsg(t, conn))
+	assert.Equal(t, `{"id":"1","payload":{"data":{"ticks":2}},"type":"next"}`, readMsg(t, conn))
+
+	cancel()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "got %v", err)
+			break
+		}
+	}
+	assert.NoError(t, <-served)
+}
+
+func readMsg(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+
+	// re-encode to drop the null fields and sort the keys.
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &m))
+	for k, v := range m {
+		if v == nil {
+			delete(m, k)
+		}
+	}
+	out, _ := json.Marshal(m)
+	return string(out)
+}
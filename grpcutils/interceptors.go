@@ -0,0 +1,130 @@
+package grpcutils
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/httputils"
+	"github.com/spy16/moonshot/log"
+)
+
+// Metadata keys for request correlation (same as the HTTP headers).
+const (
+	mdRequestID   = "x-request-id"
+	mdTraceParent = "traceparent"
+	mdTraceState  = "tracestate"
+)
+
+func unaryInterceptor(reporter httputils.PanicReporter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		ctx, startedAt := begin(ctx, info.FullMethod)
+		defer func() {
+			err = finish(ctx, reporter, recover(), err, startedAt)
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func streamInterceptor(reporter httputils.PanicReporter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx, startedAt := begin(ss.Context(), info.FullMethod)
+		defer func() {
+			err = finish(ctx, reporter, recover(), err, startedAt)
+		}()
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// begin sets up the request ID, trace context, identity and the log
+// fields similar to the RequestID and AccessLog HTTP middlewares.
+func begin(ctx context.Context, method string) (context.Context, time.Time) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = httputils.Correlate(ctx, first(md.Get(mdRequestID)), first(md.Get(mdTraceParent)), md.Get(mdTraceState)...)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(mdRequestID, httputils.RequestIDFrom(ctx)))
+
+	fields := log.Fields{"method": method}
+	p, _ := peer.FromContext(ctx)
+	if p != nil && p.Addr != nil {
+		fields["remote_addr"] = p.Addr.String()
+	}
+
+	ctx = log.InjectFields(ctx, fields)
+	if p != nil {
+		ctx = withCertIdentity(ctx, p.AuthInfo)
+	}
+	return ctx, time.Now()
+}
+
+// withCertIdentity sets the identity from the verified client certificate
+// (mTLS), similar to httputils.ClientCertIdentity.
+func withCertIdentity(ctx context.Context, authInfo credentials.AuthInfo) context.Context {
+	info, ok := authInfo.(credentials.TLSInfo)
+	if !ok {
+		return ctx
+	}
+	if chains := info.State.VerifiedChains; len(chains) > 0 && len(chains[0]) > 0 {
+		return httputils.WithIdentity(ctx, httputils.CertIdentity(chains[0][0]))
+	}
+	return ctx
+}
+
+// finish recovers from panics, converts the error to gRPC status and logs
+// the request.
+func finish(ctx context.Context, reporter httputils.PanicReporter, rec interface{}, err error, startedAt time.Time) error {
+	if rec != nil {
+		stack := debug.Stack()
+		log.Error(ctx, "recovered from panic", "panic", rec, "stack", string(stack))
+		httputils.ReportPanic(ctx, reporter, rec, stack)
+		err = errors.ErrInternal
+	}
+
+	code := codes.OK
+	if err != nil {
+		st := ToStatus(err)
+		code, err = st.Code(), st.Err()
+	}
+
+	kv := []interface{}{
+		"code", code.String(),
+		"latency", time.Since(startedAt).String(),
+	}
+	if isServerError(code) {
+		log.Error(ctx, "request completed", append(kv, "err", err)...)
+	} else {
+		log.Info(ctx, "request completed", kv...)
+	}
+	return err
+}
+
+// isServerError returns true for the codes equivalent of HTTP 5xx.
+func isServerError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unimplemented,
+		codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+func first(vals []string) string {
+	if len(vals) == 0 {
+		return ""
+	}
+	return vals[0]
+}
+
+// serverStream overrides the context of the stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ss *serverStream) Context() context.Context { return ss.ctx }
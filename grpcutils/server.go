@@ -0,0 +1,122 @@
+// Package grpcutils provides a gRPC server with the moonshot conventions
+// (request-id, access logs, panic recovery and errors.Error mapping), health
+// and reflection services and helpers to serve it standalone or alongside
+// the HTTP handlers on the same port.
+package grpcutils
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/spy16/moonshot/httputils"
+	"github.com/spy16/moonshot/log"
+)
+
+// Options controls the behaviour of the gRPC server.
+type Options struct {
+	// PanicReporter is invoked when a handler panics.
+	PanicReporter httputils.PanicReporter
+
+	// DisableReflection disables the server reflection service.
+	DisableReflection bool
+
+	// ServerOptions are passed to grpc.NewServer(). Interceptors added
+	// using grpc.ChainUnaryInterceptor() and grpc.ChainStreamInterceptor()
+	// run after the built-in ones.
+	ServerOptions []grpc.ServerOption
+}
+
+// Server is a gRPC server with the health service registered.
+type Server struct {
+	*grpc.Server
+
+	// Health can be used to set the serving status of the services. All
+	// the services are set to NOT_SERVING on shutdown.
+	Health *health.Server
+}
+
+// NewServer returns a gRPC server with the built-in interceptors, health
+// and reflection services.
+func NewServer(opts Options) *Server {
+	serverOpts := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInterceptor(opts.PanicReporter)),
+		grpc.ChainStreamInterceptor(streamInterceptor(opts.PanicReporter)),
+	}, opts.ServerOptions...)
+
+	srv := &Server{
+		Server: grpc.NewServer(serverOpts...),
+		Health: health.NewServer(),
+	}
+	healthpb.RegisterHealthServer(srv.Server, srv.Health)
+	if !opts.DisableReflection {
+		reflection.Register(srv.Server)
+	}
+	return srv
+}
+
+// Serve starts the gRPC server on the listener (see httputils.Listen).
+// Server stops gracefully when the context is cancelled and forcefully
+// after the grace period.
+func (s *Server) Serve(ctx context.Context, gracePeriod time.Duration, lis net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		s.Health.Shutdown()
+
+		log.Warnf(ctx, "grpc server shutting down (reason: context_cancelled)")
+		stopped := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+			log.Infof(ctx, "grpc graceful shutdown complete")
+
+		case <-time.After(gracePeriod):
+			log.Errorf(ctx, "grpc graceful shutdown timed out, stopping forcefully")
+			s.Stop()
+		}
+	}()
+
+	err := s.Server.Serve(lis)
+	if err != nil && err != grpc.ErrServerStopped {
+		return err
+	}
+	return nil
+}
+
+// Handler returns a handler that routes the gRPC requests (HTTP/2 with
+// 'application/grpc' content-type) to the gRPC server and the rest to
+// next. Use httputils.WithH2C() with GracefulServe for serving gRPC
+// without TLS. The server read/write timeouts are not applied to the gRPC
+// requests (same as Serve). Health status is set to NOT_SERVING when ctx
+// is cancelled.
+func (s *Server) Handler(ctx context.Context, next http.Handler) http.Handler {
+	go func() {
+		<-ctx.Done()
+		s.Health.Shutdown()
+	}()
+
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if req.ProtoMajor == 2 && strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc") {
+			// HTTP/2 applies the server read/write timeouts to each stream
+			// which would reset the long-running streaming RPCs.
+			rc := http.NewResponseController(wr)
+			_ = rc.SetReadDeadline(time.Time{})
+			_ = rc.SetWriteDeadline(time.Time{})
+
+			s.Server.ServeHTTP(wr, req)
+			return
+		}
+		next.ServeHTTP(wr, req)
+	})
+}
@@ -0,0 +1,320 @@
+package grpcutils_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/grpcutils"
+	"github.com/spy16/moonshot/httputils"
+	"github.com/spy16/moonshot/log/logtest"
+)
+
+func TestServer_Handler(t *testing.T) {
+	t.Parallel()
+
+	var panicked interface{}
+	srv := grpcutils.NewServer(grpcutils.Options{
+		PanicReporter: func(_ context.Context, rec interface{}, _ []byte) { panicked = rec },
+	})
+	srv.RegisterService(&testServiceDesc, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logCtx, logs := logtest.Capture(t)
+	h := srv.Handler(ctx, http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		httputils.Respond(wr, req, http.StatusOK, map[string]string{"proto": req.Proto})
+	}))
+	ts := httptest.NewUnstartedServer(h2c.NewHandler(h, &http2.Server{}))
+	ts.Config.BaseContext = func(net.Listener) context.Context { return logCtx }
+	ts.Start()
+	defer ts.Close()
+
+	conn, err := grpc.NewClient(ts.Listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	t.Run("Health", func(t *testing.T) {
+		resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	})
+
+	t.Run("RequestID", func(t *testing.T) {
+		var md metadata.MD
+		reqCtx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req-1")
+		err := conn.Invoke(reqCtx, "/test.Test/Echo", &emptypb.Empty{}, &emptypb.Empty{}, grpc.Header(&md))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"req-1"}, md.Get("x-request-id"))
+
+		err = conn.Invoke(context.Background(), "/test.Test/Echo", &emptypb.Empty{}, &emptypb.Empty{}, grpc.Header(&md))
+		require.NoError(t, err)
+		assert.Len(t, md.Get("x-request-id")[0], 32)
+	})
+
+	t.Run("TraceContext", func(t *testing.T) {
+		const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		reqCtx := metadata.AppendToOutgoingContext(context.Background(),
+			"x-request-id", "req-trace",
+			"traceparent", "00-"+traceID+"-00f067aa0ba902b7-01",
+		)
+		err := conn.Invoke(reqCtx, "/test.Test/Echo", &emptypb.Empty{}, &emptypb.Empty{})
+		require.NoError(t, err)
+
+		var got []interface{}
+		for _, e := range logs.Entries() {
+			if e.Fields["request_id"] == "req-trace" {
+				got = append(got, e.Fields["trace_id"])
+			}
+		}
+		assert.Equal(t, []interface{}{traceID}, got)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		err := conn.Invoke(context.Background(), "/test.Test/Fail", &emptypb.Empty{}, &emptypb.Empty{})
+		st := status.Convert(err)
+		assert.Equal(t, codes.InvalidArgument, st.Code())
+		assert.Equal(t, "name is required", st.Message())
+		require.Len(t, st.Details(), 2)
+		assert.Equal(t, "bad_request", st.Details()[0].(*errdetails.ErrorInfo).Reason)
+		assert.Equal(t, "name", st.Details()[1].(*errdetails.BadRequest).FieldViolations[0].Field)
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		err := conn.Invoke(context.Background(), "/test.Test/Panic", &emptypb.Empty{}, &emptypb.Empty{})
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Equal(t, "boom", panicked)
+
+		var msgs []string
+		for _, e := range logs.Entries() {
+			msgs = append(msgs, e.Message)
+		}
+		assert.Contains(t, msgs, "recovered from panic")
+	})
+
+	t.Run("HTTP", func(t *testing.T) {
+		resp, err := http.Get(ts.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Shutdown", func(t *testing.T) {
+		cancel()
+		assert.Eventually(t, func() bool {
+			resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+			return err == nil && resp.Status == healthpb.HealthCheckResponse_NOT_SERVING
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestServer_Serve(t *testing.T) {
+	t.Parallel()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := grpcutils.NewServer(grpcutils.Options{})
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, time.Second, lis) }()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve did not return after the context was cancelled")
+	}
+}
+
+func TestServer_GracefulServe(t *testing.T) {
+	t.Parallel()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+
+	srv := grpcutils.NewServer(grpcutils.Options{})
+	srv.RegisterService(&testServiceDesc, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := srv.Handler(ctx, http.NotFoundHandler())
+	served := make(chan error, 1)
+	go func() { served <- httputils.GracefulServe(ctx, 10*time.Second, lis, h, httputils.WithH2C()) }()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Eventually(t, func() bool {
+		_, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// in-flight calls must complete before GracefulServe returns.
+	called := make(chan error, 1)
+	go func() {
+		called <- conn.Invoke(context.Background(), "/test.Test/Slow", &emptypb.Empty{}, &emptypb.Empty{})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	assert.NoError(t, <-served)
+	select {
+	case err := <-called:
+		assert.NoError(t, err)
+	default:
+		t.Fatal("GracefulServe returned before the in-flight call completed")
+	}
+}
+
+func TestServer_HandlerStreamTimeout(t *testing.T) {
+	t.Parallel()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	srv := grpcutils.NewServer(grpcutils.Options{})
+	srv.RegisterService(&testServiceDesc, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// streams outliving the HTTP server timeouts must not be reset.
+	timeouts := httputils.Timeouts{ReadHeader: time.Second, Read: 200 * time.Millisecond, Write: 200 * time.Millisecond}
+	go func() {
+		_ = httputils.GracefulServe(ctx, time.Second, lis, srv.Handler(ctx, http.NotFoundHandler()),
+			httputils.WithTimeouts(timeouts), httputils.WithH2C())
+	}()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	stream, err := conn.NewStream(context.Background(), &testServiceDesc.Streams[0], "/test.Test/Tick")
+	require.NoError(t, err)
+	require.NoError(t, stream.SendMsg(&emptypb.Empty{}))
+	require.NoError(t, stream.CloseSend())
+
+	received := 0
+	for {
+		err := stream.RecvMsg(&emptypb.Empty{})
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		received++
+	}
+	assert.Equal(t, 6, received)
+}
+
+func TestToStatus(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		title    string
+		err      error
+		wantCode codes.Code
+		wantMsg  string
+	}{
+		{title: "Nil", err: nil, wantCode: codes.OK},
+		{title: "Status", err: status.Error(codes.Aborted, "aborted"), wantCode: codes.Aborted, wantMsg: "aborted"},
+		{title: "Canceled", err: context.Canceled, wantCode: codes.Canceled, wantMsg: "context canceled"},
+		{title: "DeadlineExceeded", err: context.DeadlineExceeded, wantCode: codes.DeadlineExceeded, wantMsg: "Request timed out"},
+		{title: "NotFound", err: errors.ErrNotFound.WithMsgf("user not found"), wantCode: codes.NotFound, wantMsg: "user not found"},
+		{title: "Conflict", err: errors.ErrConflict, wantCode: codes.AlreadyExists},
+		{title: "Forbidden", err: errors.ErrForbidden, wantCode: codes.PermissionDenied},
+		{title: "RateLimited", err: errors.ErrRateLimited, wantCode: codes.ResourceExhausted},
+		{title: "Unknown", err: assert.AnError, wantCode: codes.Internal},
+	}
+
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.title, func(t *testing.T) {
+			st := grpcutils.ToStatus(tt.err)
+			assert.Equal(t, tt.wantCode, st.Code())
+			if tt.wantMsg != "" {
+				assert.Equal(t, tt.wantMsg, st.Message())
+			}
+		})
+	}
+}
+
+var testServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.Test",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Echo", Handler: unaryHandler("/test.Test/Echo", func(context.Context) error { return nil })},
+		{MethodName: "Fail", Handler: unaryHandler("/test.Test/Fail", func(context.Context) error {
+			return errors.ErrInvalid.WithMsgf("name is required").WithFields(errors.FieldError{Field: "name", Reason: "required"})
+		})},
+		{MethodName: "Slow", Handler: unaryHandler("/test.Test/Slow", func(context.Context) error {
+			time.Sleep(200 * time.Millisecond)
+			return nil
+		})},
+		{MethodName: "Panic", Handler: unaryHandler("/test.Test/Panic", func(context.Context) error { panic("boom") })},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Tick",
+			ServerStreams: true,
+			Handler: func(_ interface{}, stream grpc.ServerStream) error {
+				if err := stream.RecvMsg(&emptypb.Empty{}); err != nil {
+					return err
+				}
+				for i := 0; i < 6; i++ {
+					time.Sleep(100 * time.Millisecond)
+					if err := stream.SendMsg(&emptypb.Empty{}); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	},
+}
+
+func unaryHandler(method string, fn func(ctx context.Context) error) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		in := &emptypb.Empty{}
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		h := func(ctx context.Context, _ interface{}) (interface{}, error) {
+			return &emptypb.Empty{}, fn(ctx)
+		}
+		if interceptor == nil {
+			return h(ctx, in)
+		}
+		return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: method}, h)
+	}
+}
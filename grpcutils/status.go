@@ -0,0 +1,107 @@
+package grpcutils
+
+import (
+	"context"
+	stderrors "errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/spy16/moonshot/errors"
+)
+
+// ToStatus converts the error to gRPC status. Status errors are returned
+// as is. Other errors are converted using errors.E() and mapped to codes
+// similar to httputils.ErrorStatus(). Error code and cause are added as
+// ErrorInfo, field errors as BadRequest and retry-after as RetryInfo
+// details.
+func ToStatus(err error) *status.Status {
+	if err == nil {
+		return status.New(codes.OK, "")
+	}
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+
+	// errors.Error is not checked against the context errors since
+	// ErrInternal matches all the unknown errors.
+	if _, isErr := err.(errors.Error); !isErr {
+		switch {
+		case stderrors.Is(err, context.Canceled):
+			return status.New(codes.Canceled, err.Error())
+
+		case stderrors.Is(err, context.DeadlineExceeded):
+			err = errors.ErrTimeout.WithCausef("%v", err)
+		}
+	}
+
+	e := errors.E(err)
+	msg := e.Message
+	if msg == "" {
+		msg = e.Error()
+	}
+	st := status.New(Code(e), msg)
+
+	info := &errdetails.ErrorInfo{Reason: e.Code}
+	if e.Cause != "" {
+		info.Metadata = map[string]string{"cause": e.Cause}
+	}
+	details := []protoadapt.MessageV1{info}
+
+	if fields := e.Fields(); len(fields) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, fe := range fields {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       fe.Field,
+				Description: fe.Reason,
+			})
+		}
+		details = append(details, br)
+	}
+
+	if d := e.RetryAfter(); d > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(d)})
+	}
+
+	withDetails, detailErr := st.WithDetails(details...)
+	if detailErr != nil {
+		return st
+	}
+	return withDetails
+}
+
+// Code returns the gRPC code for the error based on its category. Unknown
+// errors are mapped to codes.Internal.
+func Code(err error) codes.Code {
+	switch {
+	case errors.Is(err, errors.ErrInvalid):
+		return codes.InvalidArgument
+
+	case errors.Is(err, errors.ErrNotFound):
+		return codes.NotFound
+
+	case errors.Is(err, errors.ErrConflict):
+		return codes.AlreadyExists
+
+	case errors.Is(err, errors.ErrForbidden):
+		return codes.PermissionDenied
+
+	case errors.Is(err, errors.ErrUnsupported):
+		return codes.Unimplemented
+
+	case errors.Is(err, errors.ErrUnavailable):
+		return codes.Unavailable
+
+	case errors.Is(err, errors.ErrTimeout):
+		return codes.DeadlineExceeded
+
+	case errors.Is(err, errors.ErrRateLimited), errors.Is(err, errors.ErrTooLarge):
+		return codes.ResourceExhausted
+
+	default:
+		return codes.Internal
+	}
+}
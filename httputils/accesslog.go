@@ -0,0 +1,115 @@
+package httputils
+
+import (
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+
+	"github.com/spy16/moonshot/log"
+)
+
+// AccessLogOptions controls the behaviour of the AccessLog middleware.
+type AccessLogOptions struct {
+	// SampleRate is the fraction (0 to 1] of successful requests to be
+	// logged. Requests resulting in 5xx are always logged. Zero value
+	// logs all requests.
+	SampleRate float64
+
+	// Exclude is the list of paths that are not logged. A trailing '*'
+	// matches by prefix (e.g., "/static/*").
+	Exclude []string
+}
+
+// AccessLog returns a middleware that logs every request after it is
+// served. Request fields (method, path, remote_ip) are also injected into
+// the request context so that logs from the handlers carry them. Install
+// RequestID before this middleware to have request_id in the logs.
+func AccessLog(opts AccessLogOptions) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+			if isExcluded(opts.Exclude, req.URL.Path) {
+				next.ServeHTTP(wr, req)
+				return
+			}
+
+			ctx := log.InjectFields(req.Context(), log.Fields{
+				"method":    req.Method,
+				"path":      req.URL.Path,
+				"remote_ip": remoteIP(req),
+			})
+			req = req.WithContext(ctx)
+
+			ww := middleware.NewWrapResponseWriter(wr, req.ProtoMajor)
+			startedAt := time.Now()
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+
+				if status < http.StatusInternalServerError && !sampled(opts.SampleRate) {
+					return
+				}
+
+				kv := []interface{}{
+					"route", routePattern(req),
+					"status", status,
+					"bytes", ww.BytesWritten(),
+					"latency", time.Since(startedAt).String(),
+				}
+				if status >= http.StatusInternalServerError {
+					log.Error(ctx, "request completed", kv...)
+				} else {
+					log.Info(ctx, "request completed", kv...)
+				}
+			}()
+
+			next.ServeHTTP(ww, req)
+		})
+	}
+}
+
+func isExcluded(patterns []string, path string) bool {
+	for _, p := range patterns {
+		if matchPath(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath matches the path exactly or by prefix if the pattern ends
+// with '*'.
+func matchPath(pattern, path string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(path, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == path
+}
+
+func sampled(rate float64) bool {
+	if rate <= 0 || rate >= 1 {
+		return true
+	}
+	return rand.Float64() < rate
+}
+
+func routePattern(req *http.Request) string {
+	if rctx := chi.RouteContext(req.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+	return ""
+}
+
+func remoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
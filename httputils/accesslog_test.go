@@ -0,0 +1,102 @@
+package httputils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/httputils"
+	"github.com/spy16/moonshot/log"
+	"github.com/spy16/moonshot/log/logtest"
+)
+
+func TestAccessLog(t *testing.T) {
+	t.Parallel()
+
+	newRouter := func(opts httputils.AccessLogOptions) *chi.Mux {
+		r := chi.NewRouter()
+		r.Use(httputils.RequestID, httputils.AccessLog(opts))
+		r.Get("/health", func(wr http.ResponseWriter, req *http.Request) {})
+		r.Get("/users/{id}", func(wr http.ResponseWriter, req *http.Request) {
+			log.Info(req.Context(), "loading user")
+			_, _ = wr.Write([]byte("hello"))
+		})
+		r.Get("/fail", func(wr http.ResponseWriter, req *http.Request) {
+			wr.WriteHeader(http.StatusInternalServerError)
+		})
+		return r
+	}
+
+	serve := func(t *testing.T, h http.Handler, target string) (*logtest.Recorder, *httptest.ResponseRecorder) {
+		ctx, rec := logtest.Capture(t)
+		req := httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+		req.RemoteAddr = "198.51.100.7:4242"
+		req.Header.Set(httputils.HeaderRequestID, "req-1")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rec, rr
+	}
+
+	t.Run("Fields", func(t *testing.T) {
+		rec, rr := serve(t, newRouter(httputils.AccessLogOptions{}), "/users/42")
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		entries := rec.Entries()
+		require.Len(t, entries, 2)
+
+		handlerLog := entries[0]
+		assert.Equal(t, "loading user", handlerLog.Message)
+		assert.Equal(t, "req-1", handlerLog.Fields["request_id"])
+		assert.Equal(t, "/users/42", handlerLog.Fields["path"])
+		assert.Equal(t, http.MethodGet, handlerLog.Fields["method"])
+		assert.Equal(t, "198.51.100.7", handlerLog.Fields["remote_ip"])
+
+		accessLog := entries[1]
+		assert.Equal(t, "request completed", accessLog.Message)
+		assert.Equal(t, log.InfoLevel, accessLog.Level)
+		assert.Equal(t, "/users/{id}", accessLog.Fields["route"])
+		assert.Equal(t, http.StatusOK, accessLog.Fields["status"])
+		assert.Equal(t, 5, accessLog.Fields["bytes"])
+		assert.NotEmpty(t, accessLog.Fields["latency"])
+		assert.Equal(t, "198.51.100.7", accessLog.Fields["remote_ip"])
+		assert.Equal(t, "req-1", accessLog.Fields["request_id"])
+	})
+
+	t.Run("RequestIDNotFromHeader", func(t *testing.T) {
+		// without the RequestID middleware, the client header must not
+		// be logged as the request ID.
+		r := chi.NewRouter()
+		r.Use(httputils.AccessLog(httputils.AccessLogOptions{}))
+		r.Get("/", func(wr http.ResponseWriter, req *http.Request) {})
+
+		rec, _ := serve(t, r, "/")
+		entries := rec.Entries()
+		if assert.Len(t, entries, 1) {
+			assert.NotContains(t, entries[0].Fields, "request_id")
+		}
+	})
+
+	t.Run("Excluded", func(t *testing.T) {
+		rec, rr := serve(t, newRouter(httputils.AccessLogOptions{Exclude: []string{"/health"}}), "/health")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Empty(t, rec.Entries())
+	})
+
+	t.Run("SampledKeepsErrors", func(t *testing.T) {
+		h := newRouter(httputils.AccessLogOptions{SampleRate: 1e-9})
+		for i := 0; i < 10; i++ {
+			rec, _ := serve(t, h, "/fail")
+			entries := rec.Filter(log.ErrorLevel)
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, http.StatusInternalServerError, entries[0].Fields["status"])
+			}
+
+			rec, _ = serve(t, h, "/users/42")
+			assert.Equal(t, []string{"loading user"}, rec.Messages(), "successful requests must be sampled")
+		}
+	})
+}
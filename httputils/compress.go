@@ -0,0 +1,387 @@
+package httputils
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Supported content encodings for the Compress middleware.
+const (
+	EncodingBrotli  = "br"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// DefaultCompressTypes is the default content-type allowlist used by the
+// Compress middleware.
+var DefaultCompressTypes = []string{
+	"text/*",
+	"application/json",
+	"application/*+json",
+	"application/x-ndjson",
+	"application/javascript",
+	"application/xml",
+	"application/*+xml",
+	"application/yaml",
+	"application/x-yaml",
+	"application/wasm",
+	"image/svg+xml",
+}
+
+// CompressOptions controls the behaviour of the Compress middleware.
+type CompressOptions struct {
+	// Level is the compression level. Values are clamped to the range
+	// supported by each encoder. Zero uses the default levels.
+	Level int
+
+	// MinSize is the minimum response size in bytes for compression to
+	// be applied. Defaults to 1024.
+	MinSize int
+
+	// ContentTypes is the allowlist of media types to compress. Entries
+	// can have a single '*' wildcard (e.g., 'text/*'). Defaults to
+	// DefaultCompressTypes.
+	ContentTypes []string
+
+	// Encodings is the list of enabled encodings in order of preference
+	// when client accepts multiple with same quality. Defaults to br,
+	// gzip and deflate.
+	Encodings []string
+}
+
+// Compress returns a middleware that compresses the response bodies
+// using the best encoding accepted by the client ('Accept-Encoding').
+// Responses smaller than MinSize, with content-types not in allowlist,
+// already encoded or partial are sent as is. Content-Length set by the
+// handler is removed when response is compressed.
+func Compress(opts CompressOptions) func(next http.Handler) http.Handler {
+	if opts.MinSize <= 0 {
+		opts.MinSize = 1024
+	}
+	if len(opts.ContentTypes) == 0 {
+		opts.ContentTypes = DefaultCompressTypes
+	}
+	if len(opts.Encodings) == 0 {
+		opts.Encodings = []string{EncodingBrotli, EncodingGzip, EncodingDeflate}
+	}
+
+	pools := map[string]*sync.Pool{}
+	for _, enc := range opts.Encodings {
+		enc = strings.ToLower(enc)
+		if newEnc := newEncoder(enc, opts.Level); newEnc != nil {
+			pools[enc] = &sync.Pool{New: func() interface{} { return newEnc() }}
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+			enc := negotiateEncoding(req.Header.Get("Accept-Encoding"), opts.Encodings, pools)
+			if enc == "" || req.Method == http.MethodHead {
+				next.ServeHTTP(wr, req)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: wr,
+				opts:           &opts,
+				encoding:       enc,
+				pool:           pools[enc],
+			}
+			defer cw.close()
+
+			var w http.ResponseWriter = cw
+			if _, ok := wr.(http.Hijacker); ok {
+				w = &compressHijacker{cw}
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+type encoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+	Flush() error
+}
+
+func newEncoder(enc string, level int) func() encoder {
+	switch enc {
+	case EncodingGzip:
+		level = clampLevel(level, gzip.DefaultCompression, gzip.BestSpeed, gzip.BestCompression)
+		return func() encoder {
+			w, _ := gzip.NewWriterLevel(io.Discard, level)
+			return w
+		}
+
+	case EncodingDeflate:
+		level = clampLevel(level, flate.DefaultCompression, flate.BestSpeed, flate.BestCompression)
+		return func() encoder {
+			w, _ := flate.NewWriter(io.Discard, level)
+			return w
+		}
+
+	case EncodingBrotli:
+		level = clampLevel(level, 5, brotli.BestSpeed, brotli.BestCompression)
+		return func() encoder {
+			return brotli.NewWriterLevel(io.Discard, level)
+		}
+	}
+	return nil
+}
+
+func clampLevel(level, def, min, max int) int {
+	switch {
+	case level == 0:
+		return def
+	case level < min:
+		return min
+	case level > max:
+		return max
+	}
+	return level
+}
+
+// negotiateEncoding picks the enabled encoding with highest quality in
+// the 'Accept-Encoding' header. Ties are resolved using the server side
+// preference order.
+func negotiateEncoding(accept string, preferred []string, pools map[string]*sync.Pool) string {
+	if accept == "" {
+		return ""
+	}
+
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		qualities[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range preferred {
+		enc = strings.ToLower(enc)
+		if pools[enc] == nil {
+			continue
+		}
+
+		q, found := qualities[enc]
+		if !found {
+			q, found = qualities["*"]
+		}
+		if found && q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the response until the compression decision
+// can be made (i.e., MinSize bytes are written, handler returns or the
+// response is flushed).
+type compressWriter struct {
+	http.ResponseWriter
+
+	opts     *CompressOptions
+	encoding string
+	pool     *sync.Pool
+
+	status  int
+	decided bool
+	buf     []byte
+	enc     encoder
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.decided || cw.status != 0 {
+		return
+	}
+
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status = status
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if !cw.eligible() {
+			return len(p), cw.decide(false)
+		}
+		if len(cw.buf) < cw.opts.MinSize && !cw.knownLarge() {
+			return len(p), nil
+		}
+		return len(p), cw.decide(true)
+	}
+
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		_ = cw.decide(cw.eligible() && len(cw.buf) > 0)
+	}
+
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ReadFrom is implemented since wrappers like chi's WrapResponseWriter
+// expect it when the underlying writer supports hijacking or flushing.
+func (cw *compressWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(writerOnly{cw}, r)
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter { return cw.ResponseWriter }
+
+// eligible returns true if the response can be compressed based on the
+// status and headers.
+func (cw *compressWriter) eligible() bool {
+	switch cw.status {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent,
+		http.StatusSwitchingProtocols:
+		return false
+	}
+
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+
+	if cl := h.Get("Content-Length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n < cw.opts.MinSize {
+			return false
+		}
+	}
+
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		// content-type will be sniffed by net/http if not set. we do the
+		// same here so that the decision is made on the actual type.
+		if len(cw.buf) == 0 {
+			return true
+		}
+		ct = http.DetectContentType(cw.buf)
+	}
+	return cw.typeAllowed(ct)
+}
+
+func (cw *compressWriter) knownLarge() bool {
+	n, err := strconv.Atoi(cw.Header().Get("Content-Length"))
+	return err == nil && n >= cw.opts.MinSize
+}
+
+func (cw *compressWriter) typeAllowed(ct string) bool {
+	mediaType, _, _ := strings.Cut(ct, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	for _, pattern := range cw.opts.ContentTypes {
+		pattern = strings.ToLower(pattern)
+		if pattern == mediaType {
+			return true
+		}
+		if idx := strings.IndexByte(pattern, '*'); idx >= 0 {
+			prefix, suffix := pattern[:idx], pattern[idx+1:]
+			if len(mediaType) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(mediaType, prefix) && strings.HasSuffix(mediaType, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decide writes the headers and buffered data with or without the
+// compression.
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+
+	h := cw.Header()
+	if compress {
+		if h.Get("Content-Type") == "" {
+			h.Set("Content-Type", http.DetectContentType(cw.buf))
+		}
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+		addVary(h, "Accept-Encoding")
+
+		cw.enc = cw.pool.Get().(encoder)
+		cw.enc.Reset(cw.ResponseWriter)
+	} else if h.Get("Content-Encoding") == "" && cw.typeAllowed(h.Get("Content-Type")) {
+		// other encodings may be chosen for the same resource with
+		// different size or request.
+		addVary(h, "Accept-Encoding")
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if len(cw.buf) == 0 {
+		return nil
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if cw.status == 0 {
+			// handler did not write anything.
+			return
+		}
+		_ = cw.decide(false)
+	}
+
+	if cw.enc != nil {
+		_ = cw.enc.Close()
+		cw.enc.Reset(io.Discard)
+		cw.pool.Put(cw.enc)
+		cw.enc = nil
+	}
+}
+
+// compressHijacker is used when the underlying writer supports hijacking
+// (e.g., for websocket upgrades).
+type compressHijacker struct{ *compressWriter }
+
+func (ch *compressHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	ch.decided = true
+	return ch.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// writerOnly hides the ReadFrom method to avoid recursion in io.Copy.
+type writerOnly struct{ io.Writer }
@@ -0,0 +1,240 @@
+package httputils_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/httputils"
+)
+
+func TestCompress(t *testing.T) {
+	t.Parallel()
+
+	large := strings.Repeat(`{"hello":"world"}`, 200)
+
+	table := []struct {
+		title    string
+		accept   string
+		ct       string
+		body     string
+		status   int
+		setLen   bool
+		preset   string
+		wantEnc  string
+		wantVary bool
+	}{
+		{
+			title:    "GzipLargeJSON",
+			accept:   "gzip",
+			ct:       "application/json",
+			body:     large,
+			setLen:   true,
+			wantEnc:  "gzip",
+			wantVary: true,
+		},
+		{
+			title:    "PrefersBrotliOnTie",
+			accept:   "gzip, deflate, br",
+			ct:       "text/html; charset=utf-8",
+			body:     large,
+			wantEnc:  "br",
+			wantVary: true,
+		},
+		{
+			title:    "HigherQualityWins",
+			accept:   "br;q=0.5, deflate;q=0.9",
+			ct:       "application/problem+json",
+			body:     large,
+			wantEnc:  "deflate",
+			wantVary: true,
+		},
+		{
+			title:    "WildcardAccept",
+			accept:   "*;q=0.8, br;q=0",
+			ct:       "text/plain",
+			body:     large,
+			wantEnc:  "gzip",
+			wantVary: true,
+		},
+		{
+			title:    "SniffedType",
+			accept:   "gzip",
+			body:     "<html><body>" + large + "</body></html>",
+			wantEnc:  "gzip",
+			wantVary: true,
+		},
+		{
+			title:    "SmallBody",
+			accept:   "gzip",
+			ct:       "application/json",
+			body:     `{"small":true}`,
+			setLen:   true,
+			wantVary: true,
+		},
+		{
+			title:  "TypeNotAllowed",
+			accept: "gzip",
+			ct:     "image/png",
+			body:   large,
+			setLen: true,
+		},
+		{
+			title:  "AlreadyEncoded",
+			accept: "gzip",
+			ct:     "application/json",
+			body:   large,
+			preset: "gzip",
+		},
+		{
+			title:  "NotAccepted",
+			accept: "",
+			ct:     "application/json",
+			body:   large,
+		},
+		{
+			title:  "NoContent",
+			accept: "gzip",
+			status: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.title, func(t *testing.T) {
+			t.Parallel()
+
+			h := httputils.Compress(httputils.CompressOptions{})(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+				if tt.ct != "" {
+					wr.Header().Set("Content-Type", tt.ct)
+				}
+				if tt.setLen {
+					wr.Header().Set("Content-Length", strconv.Itoa(len(tt.body)))
+				}
+				if tt.preset != "" {
+					wr.Header().Set("Content-Encoding", tt.preset)
+				}
+				if tt.status != 0 {
+					wr.WriteHeader(tt.status)
+				}
+				// write in chunks to exercise buffering.
+				for i := 0; i < len(tt.body); i += 100 {
+					end := i + 100
+					if end > len(tt.body) {
+						end = len(tt.body)
+					}
+					_, _ = wr.Write([]byte(tt.body[i:end]))
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			wantStatus := tt.status
+			if wantStatus == 0 {
+				wantStatus = http.StatusOK
+			}
+			assert.Equal(t, wantStatus, rec.Code)
+			assert.Equal(t, tt.wantVary, rec.Header().Get("Vary") == "Accept-Encoding")
+
+			if tt.wantEnc == "" {
+				if tt.preset == "" {
+					assert.Empty(t, rec.Header().Get("Content-Encoding"))
+				}
+				assert.Equal(t, tt.body, rec.Body.String())
+				return
+			}
+
+			assert.Equal(t, tt.wantEnc, rec.Header().Get("Content-Encoding"))
+			assert.Empty(t, rec.Header().Get("Content-Length"))
+			assert.Less(t, rec.Body.Len(), len(tt.body))
+			assert.Equal(t, tt.body, decode(t, tt.wantEnc, rec.Body.Bytes()))
+		})
+	}
+}
+
+func TestCompress_Flush(t *testing.T) {
+	t.Parallel()
+
+	h := httputils.Compress(httputils.CompressOptions{})(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		wr.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(wr, "data: one\n\n")
+		wr.(http.Flusher).Flush()
+		_, _ = io.WriteString(wr, "data: two\n\n")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "data: one\n\ndata: two\n\n", decode(t, "gzip", rec.Body.Bytes()))
+}
+
+func decode(t *testing.T, enc string, data []byte) string {
+	t.Helper()
+
+	var r io.Reader
+	switch enc {
+	case "gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		r = gr
+
+	case "deflate":
+		r = flate.NewReader(bytes.NewReader(data))
+
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(data))
+	}
+
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestCompress_FileServer(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	content := strings.Repeat("moonshot ", 1000)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte(content), 0o644))
+
+	// recover wraps the compress writer with chi's fancy writer which
+	// uses ReadFrom for files.
+	h := httputils.Compress(httputils.CompressOptions{})(httputils.Recover(http.FileServer(http.Dir(dir))))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/a.txt", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, content, decode(t, "gzip", body))
+}
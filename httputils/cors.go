@@ -0,0 +1,243 @@
+package httputils
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions controls the behaviour of the CORS middleware.
+type CORSOptions struct {
+	// AllowedOrigins is the list of origins allowed to make cross-origin
+	// requests. "*" allows all origins and a single '*' in the origin can
+	// be used as wildcard (e.g., "https://*.example.com").
+	AllowedOrigins []string
+
+	// AllowedMethods is the list of methods allowed in preflight requests.
+	// Simple methods (GET, HEAD, POST) are always allowed.
+	AllowedMethods []string
+
+	// AllowedHeaders is the list of request headers allowed in preflight
+	// requests. "*" allows all requested headers.
+	AllowedHeaders []string
+
+	// ExposedHeaders is the list of response headers accessible to the
+	// client scripts.
+	ExposedHeaders []string
+
+	// AllowCredentials allows requests with credentials (cookies etc.).
+	// Origin is always echoed instead of "*" when this is set.
+	AllowCredentials bool
+
+	// MaxAge is the duration for which the preflight response can be
+	// cached by the client.
+	MaxAge time.Duration
+
+	// Routes can be used to override the options for specific paths. The
+	// first route matching the request path is used. Path is matched
+	// exactly or by prefix when it ends with '*' (e.g., "/public/*").
+	Routes []CORSRoute
+}
+
+// CORSRoute represents CORS options for requests matching the path.
+type CORSRoute struct {
+	Path    string
+	Options CORSOptions
+}
+
+// CORS returns a middleware that handles cross-origin requests as per the
+// given options. Preflight requests are responded to directly and are not
+// passed to the next handler.
+func CORS(opts CORSOptions) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+			policy := opts.forPath(req.URL.Path)
+
+			isPreflight := req.Method == http.MethodOptions &&
+				req.Header.Get("Access-Control-Request-Method") != ""
+
+			if isPreflight {
+				policy.preflight(wr, req)
+				return
+			}
+
+			policy.simple(wr, req)
+			next.ServeHTTP(wr, req)
+		})
+	}
+}
+
+func (opts CORSOptions) forPath(path string) CORSOptions {
+	for _, r := range opts.Routes {
+		if matchPath(r.Path, path) {
+			return r.Options
+		}
+	}
+	return opts
+}
+
+func (opts CORSOptions) preflight(wr http.ResponseWriter, req *http.Request) {
+	h := wr.Header()
+	addVary(h, "Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers")
+
+	origin := req.Header.Get("Origin")
+	if origin == "" || !opts.originAllowed(origin) {
+		wr.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	method := strings.ToUpper(req.Header.Get("Access-Control-Request-Method"))
+	if !opts.methodAllowed(method) {
+		wr.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	reqHeaders := parseHeaderList(req.Header.Get("Access-Control-Request-Headers"))
+	if !opts.headersAllowed(reqHeaders) {
+		wr.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	opts.setOrigin(h, origin)
+	h.Set("Access-Control-Allow-Methods", method)
+	if len(reqHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(reqHeaders, ", "))
+	}
+	if opts.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge.Seconds())))
+	}
+	wr.WriteHeader(http.StatusNoContent)
+}
+
+func (opts CORSOptions) simple(wr http.ResponseWriter, req *http.Request) {
+	h := wr.Header()
+	if !opts.allowsAnyOrigin() || opts.AllowCredentials {
+		// response depends on the Origin header in these cases.
+		addVary(h, "Origin")
+	}
+
+	origin := req.Header.Get("Origin")
+	if origin == "" || !opts.originAllowed(origin) {
+		return
+	}
+
+	opts.setOrigin(h, origin)
+	if len(opts.ExposedHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", strings.Join(opts.ExposedHeaders, ", "))
+	}
+}
+
+func (opts CORSOptions) setOrigin(h http.Header, origin string) {
+	if opts.allowsAnyOrigin() && !opts.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if opts.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (opts CORSOptions) allowsAnyOrigin() bool {
+	for _, o := range opts.AllowedOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (opts CORSOptions) originAllowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, o := range opts.AllowedOrigins {
+		o = strings.ToLower(o)
+		if o == "*" || o == origin {
+			return true
+		}
+
+		if idx := strings.IndexByte(o, '*'); idx >= 0 {
+			prefix, suffix := o[:idx], o[idx+1:]
+			if len(origin) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckOrigin returns an origin check for websocket upgrades (e.g., for
+// ws.Options.CheckOrigin). Requests without 'Origin' header (i.e., from
+// non-browser clients), from the same origin as the host or matching the
+// allowed origins (same patterns as CORSOptions.AllowedOrigins) pass.
+func CheckOrigin(allowed []string) func(req *http.Request) bool {
+	opts := CORSOptions{AllowedOrigins: allowed}
+	return func(req *http.Request) bool {
+		origin := req.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, req.Host) {
+			return true
+		}
+		return opts.originAllowed(origin)
+	}
+}
+
+func (opts CORSOptions) methodAllowed(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost:
+		return true
+	}
+	for _, m := range opts.AllowedMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (opts CORSOptions) headersAllowed(reqHeaders []string) bool {
+	for _, rh := range reqHeaders {
+		allowed := false
+		for _, ah := range opts.AllowedHeaders {
+			if ah == "*" || strings.EqualFold(ah, rh) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+func parseHeaderList(s string) []string {
+	var res []string
+	for _, h := range strings.Split(s, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			res = append(res, http.CanonicalHeaderKey(h))
+		}
+	}
+	return res
+}
+
+// addVary adds the values to Vary header if not already present.
+func addVary(h http.Header, values ...string) {
+	existing := strings.Join(h.Values("Vary"), ",")
+	for _, v := range values {
+		found := false
+		for _, e := range strings.Split(existing, ",") {
+			if strings.EqualFold(strings.TrimSpace(e), v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			h.Add("Vary", v)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package httputils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spy16/moonshot/httputils"
+)
+
+func TestCORS(t *testing.T) {
+	t.Parallel()
+
+	opts := httputils.CORSOptions{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.dev.example.com"},
+		AllowedMethods:   []string{"PUT", "DELETE"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+		Routes: []httputils.CORSRoute{
+			{
+				Path: "/public/*",
+				Options: httputils.CORSOptions{
+					AllowedOrigins: []string{"*"},
+					AllowedHeaders: []string{"*"},
+				},
+			},
+		},
+	}
+
+	table := []struct {
+		title      string
+		method     string
+		path       string
+		headers    map[string]string
+		wantStatus int
+		wantHeader map[string]string
+		wantVary   []string
+	}{
+		{
+			title:      "SimpleAllowed",
+			method:     http.MethodGet,
+			path:       "/api",
+			headers:    map[string]string{"Origin": "https://app.example.com"},
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Request-ID",
+			},
+			wantVary: []string{"Origin"},
+		},
+		{
+			title:      "SimpleWildcardOrigin",
+			method:     http.MethodPost,
+			path:       "/api",
+			headers:    map[string]string{"Origin": "https://feature-1.dev.example.com"},
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin": "https://feature-1.dev.example.com",
+			},
+			wantVary: []string{"Origin"},
+		},
+		{
+			title:      "SimpleDisallowed",
+			method:     http.MethodGet,
+			path:       "/api",
+			headers:    map[string]string{"Origin": "https://evil.com"},
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": ""},
+			wantVary:   []string{"Origin"},
+		},
+		{
+			title:      "NoOrigin",
+			method:     http.MethodGet,
+			path:       "/api",
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": ""},
+			wantVary:   []string{"Origin"},
+		},
+		{
+			title:  "PreflightAllowed",
+			method: http.MethodOptions,
+			path:   "/api",
+			headers: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  "PUT",
+				"Access-Control-Request-Headers": "content-type, authorization",
+			},
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "PUT",
+				"Access-Control-Allow-Headers":     "Content-Type, Authorization",
+				"Access-Control-Max-Age":           "600",
+			},
+			wantVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			title:  "PreflightDisallowedMethod",
+			method: http.MethodOptions,
+			path:   "/api",
+			headers: map[string]string{
+				"Origin":                        "https://app.example.com",
+				"Access-Control-Request-Method": "PATCH",
+			},
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			title:  "PreflightDisallowedHeader",
+			method: http.MethodOptions,
+			path:   "/api",
+			headers: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  "PUT",
+				"Access-Control-Request-Headers": "X-Custom",
+			},
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			title:      "NonPreflightOptions",
+			method:     http.MethodOptions,
+			path:       "/api",
+			headers:    map[string]string{"Origin": "https://app.example.com"},
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": "https://app.example.com"},
+		},
+		{
+			title:      "RouteOverrideSimple",
+			method:     http.MethodGet,
+			path:       "/public/logo.png",
+			headers:    map[string]string{"Origin": "https://anyone.com"},
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":      "*",
+				"Access-Control-Allow-Credentials": "",
+				"Vary":                             "",
+			},
+		},
+		{
+			title:  "RouteOverridePreflight",
+			method: http.MethodOptions,
+			path:   "/public/upload",
+			headers: map[string]string{
+				"Origin":                         "https://anyone.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Anything",
+			},
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Headers": "X-Anything",
+			},
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.title, func(t *testing.T) {
+			h := httputils.CORS(opts)(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+				wr.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			for k, v := range tt.wantHeader {
+				assert.Equal(t, v, rec.Header().Get(k), "header %s", k)
+			}
+			if tt.wantVary != nil {
+				assert.Equal(t, tt.wantVary, rec.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		title   string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{title: "NoOrigin", want: true},
+		{title: "SameOrigin", origin: "http://api.example.com", want: true},
+		{title: "CrossOriginDenied", origin: "https://evil.example.org", want: false},
+		{title: "CrossOriginAllowed", allowed: []string{"https://app.example.com"}, origin: "https://app.example.com", want: true},
+		{title: "Wildcard", allowed: []string{"https://*.example.com"}, origin: "https://a.example.com", want: true},
+		{title: "Any", allowed: []string{"*"}, origin: "https://evil.example.org", want: true},
+	}
+
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.title, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://api.example.com/ws", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			assert.Equal(t, tt.want, httputils.CheckOrigin(tt.allowed)(req))
+		})
+	}
+}
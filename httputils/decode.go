@@ -0,0 +1,325 @@
+package httputils
+
+import (
+	"encoding"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/spy16/moonshot/errors"
+)
+
+const maxMultipartMemory = 32 << 20
+
+// Decode decodes the request into v which must be a pointer. Request body
+// is decoded based on the Content-Type (JSON or form). Fields of struct
+// with 'query', 'path', 'header' or 'form' tags are then set from the URL
+// query, chi URL params, headers and form values respectively. Supported
+// field types are strings, bools, numbers, time.Duration, time.Time
+// (RFC3339), encoding.TextUnmarshaler and slices or pointers of these.
+// Decode failures are returned as ErrInvalid with field details.
+func Decode(req *http.Request, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.ErrInternal.WithCausef("decode target must be a non-nil pointer, not %T", v)
+	}
+
+	isForm, err := decodeBody(req, v)
+	if err != nil {
+		return err
+	}
+
+	target := reflect.Indirect(rv)
+	for target.Kind() == reflect.Ptr {
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		target = target.Elem()
+	}
+	if target.Kind() != reflect.Struct {
+		return nil
+	}
+
+	sources := map[string]func(name string) []string{
+		"query": func(name string) []string {
+			return req.URL.Query()[name]
+		},
+		"path": func(name string) []string {
+			if val := chi.URLParam(req, name); val != "" {
+				return []string{val}
+			}
+			return nil
+		},
+		"header": func(name string) []string {
+			return req.Header.Values(name)
+		},
+	}
+	if isForm {
+		sources["form"] = func(name string) []string {
+			return req.PostForm[name]
+		}
+	}
+
+	var fieldErrs []errors.FieldError
+	walkFields(target, func(sf reflect.StructField, fv reflect.Value) {
+		for _, src := range []string{"form", "query", "header", "path"} {
+			name := tagName(sf, src)
+			lookup := sources[src]
+			if name == "" || lookup == nil {
+				continue
+			}
+
+			values := lookup(name)
+			if len(values) == 0 {
+				continue
+			}
+
+			if err := setField(fv, values); err != nil {
+				fieldErrs = append(fieldErrs, errors.FieldError{Field: name, Reason: err.Error()})
+			}
+		}
+	})
+
+	if len(fieldErrs) > 0 {
+		return errors.ErrInvalid.WithFields(fieldErrs...)
+	}
+	return nil
+}
+
+// decodeBody decodes the JSON body into v. For form bodies, the form is
+// parsed and isForm is true.
+func decodeBody(req *http.Request, v interface{}) (isForm bool, err error) {
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return false, nil
+	}
+
+	mediaType := "application/json"
+	if ct := req.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err = mime.ParseMediaType(ct)
+		if err != nil {
+			return false, errors.ErrInvalid.WithMsgf("invalid content-type: %v", err)
+		}
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return false, decodeJSON(req.Body, v)
+
+	case mediaType == "application/x-www-form-urlencoded":
+		if err := req.ParseForm(); err != nil {
+			return false, formErr(err)
+		}
+		return true, nil
+
+	case mediaType == "multipart/form-data":
+		if err := req.ParseMultipartForm(maxMultipartMemory); err != nil {
+			return false, formErr(err)
+		}
+		return true, nil
+
+	default:
+		return false, errors.ErrInvalid.WithMsgf("unsupported content-type '%s'", mediaType)
+	}
+}
+
+func decodeJSON(r io.Reader, v interface{}) error {
+	err := json.NewDecoder(r).Decode(v)
+	if err == nil || err == io.EOF {
+		return nil
+	}
+
+	var mbe *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case stderrors.As(err, &mbe):
+		return err
+
+	case stderrors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		return errors.ErrInvalid.WithFields(errors.FieldError{
+			Field:  field,
+			Reason: fmt.Sprintf("must be of type %s", jsonKind(typeErr.Type)),
+		})
+
+	case stderrors.As(err, &syntaxErr), err == io.ErrUnexpectedEOF:
+		return errors.ErrInvalid.WithMsgf("request body is not valid JSON")
+
+	default:
+		return errors.ErrInvalid.WithMsgf("failed to decode body: %v", err)
+	}
+}
+
+func formErr(err error) error {
+	var mbe *http.MaxBytesError
+	if stderrors.As(err, &mbe) {
+		return err
+	}
+	return errors.ErrInvalid.WithMsgf("failed to parse form: %v", err)
+}
+
+// walkFields calls fn for every exported field of the struct including
+// the fields of embedded structs.
+func walkFields(rv reflect.Value, fn func(sf reflect.StructField, fv reflect.Value)) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+
+		if sf.Anonymous {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if fv.Kind() == reflect.Ptr {
+					if fv.IsNil() {
+						if !fv.CanSet() {
+							continue
+						}
+						fv.Set(reflect.New(ft))
+					}
+					fv = fv.Elem()
+				}
+				walkFields(fv, fn)
+				continue
+			}
+		}
+
+		if sf.IsExported() {
+			fn(sf, fv)
+		}
+	}
+}
+
+// tagName returns the name from the tag (ignoring options after ',').
+func tagName(sf reflect.StructField, tag string) string {
+	name, _, _ := strings.Cut(sf.Tag.Get(tag), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+func setField(fv reflect.Value, values []string) error {
+	if fv.Kind() == reflect.Slice && !fv.Type().Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, s := range values {
+			if err := setValue(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	return setValue(fv, values[0])
+}
+
+func setValue(fv reflect.Value, s string) error {
+	if fv.Kind() == reflect.Ptr {
+		ptr := reflect.New(fv.Type().Elem())
+		if err := setValue(ptr.Elem(), s); err != nil {
+			return err
+		}
+		fv.Set(ptr)
+		return nil
+	}
+
+	switch fv.Type() {
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("must be a duration (e.g., 10s)")
+		}
+		fv.SetInt(int64(d))
+		return nil
+
+	case timeType:
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return fmt.Errorf("must be a RFC3339 timestamp")
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		if err := fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("is not valid: %v", err)
+		}
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("must be a boolean")
+		}
+		fv.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		fv.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a non-negative integer")
+		}
+		fv.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		fv.SetFloat(f)
+
+	default:
+		return fmt.Errorf("has unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
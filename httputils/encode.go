@@ -0,0 +1,336 @@
+package httputils
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// streamThreshold is the minimum length of slices that are encoded directly
+// to the response instead of being buffered first.
+const streamThreshold = 1000
+
+// Encoder writes v to w in a specific format. req can be used for format
+// specific options (e.g., '?pretty' for JSON).
+type Encoder func(w io.Writer, req *http.Request, v interface{}) error
+
+type encoderEntry struct {
+	mediaType   string
+	contentType string
+	enc         Encoder
+}
+
+var encoders = struct {
+	mu   sync.RWMutex
+	list []encoderEntry
+}{}
+
+func init() {
+	RegisterEncoder("application/json; charset=utf-8", EncodeJSON)
+	RegisterEncoder("application/msgpack", EncodeMsgPack)
+	RegisterEncoder("application/x-msgpack", EncodeMsgPack)
+	RegisterEncoder("application/cbor", EncodeCBOR)
+	RegisterEncoder("application/xml; charset=utf-8", EncodeXML)
+	RegisterEncoder("application/yaml; charset=utf-8", EncodeYAML)
+	RegisterEncoder("application/x-yaml; charset=utf-8", EncodeYAML)
+	RegisterEncoder("text/plain; charset=utf-8", EncodeText)
+	RegisterEncoder("text/xml; charset=utf-8", EncodeXML)
+	RegisterEncoder("text/yaml; charset=utf-8", EncodeYAML)
+}
+
+// RegisterEncoder registers enc to be used by Respond for the media type of
+// contentType (e.g., "application/xml; charset=utf-8"). Registering an
+// existing media type replaces its encoder. Encoders registered earlier win
+// when the request has equal preference for multiple types. The first one
+// (JSON) is used when the request does not express any preference.
+func RegisterEncoder(contentType string, enc Encoder) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		panic(fmt.Sprintf("invalid content-type '%s': %v", contentType, err))
+	}
+
+	encoders.mu.Lock()
+	defer encoders.mu.Unlock()
+
+	entry := encoderEntry{mediaType: mediaType, contentType: contentType, enc: enc}
+	for i, e := range encoders.list {
+		if e.mediaType == mediaType {
+			encoders.list[i] = entry
+			return
+		}
+	}
+	encoders.list = append(encoders.list, entry)
+}
+
+// negotiate returns the encoder for the media type most preferred by the
+// 'Accept' header of the request. Requests preferring 'text/html' (i.e.,
+// browsers) and the ones not matching any encoder get the default one.
+func negotiate(req *http.Request) encoderEntry {
+	encoders.mu.RLock()
+	defer encoders.mu.RUnlock()
+
+	accept := parseAccept(req.Header.Get("Accept"))
+	best, bestQ := encoders.list[0], 0.0
+	for _, entry := range encoders.list {
+		if q := acceptQuality(accept, entry.mediaType); q > bestQ {
+			best, bestQ = entry, q
+		}
+	}
+
+	for _, r := range accept {
+		if r.mediaType == "text/html" && r.q > 0 && r.q >= bestQ {
+			return encoders.list[0]
+		}
+	}
+	return best
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if qs, found := params["q"]; found {
+			if v, err := strconv.ParseFloat(qs, 64); err == nil {
+				q = v
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+	return ranges
+}
+
+// acceptQuality returns the quality of the most specific range matching
+// the media type.
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+
+	q, specificity := 0.0, 0
+	for _, r := range ranges {
+		s := 0
+		switch r.mediaType {
+		case mediaType:
+			s = 3
+		case typ + "/*":
+			s = 2
+		case "*/*":
+			s = 1
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+// EncodeJSON encodes v as JSON. Output is indented if the request has the
+// 'pretty' query param. Slices are encoded element by element so that
+// large lists are streamed without buffering the whole output.
+func EncodeJSON(w io.Writer, req *http.Request, v interface{}) error {
+	pretty := isPretty(req)
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice || rv.IsNil() || rv.Type().Elem().Kind() == reflect.Uint8 || isJSONMarshaler(rv.Type()) {
+		enc := json.NewEncoder(w)
+		if pretty {
+			enc.SetIndent("", "  ")
+		}
+		return enc.Encode(v)
+	}
+
+	if rv.Len() == 0 {
+		_, err := io.WriteString(w, "[]\n")
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	sep, end := ",", "]\n"
+	if pretty {
+		enc.SetIndent("  ", "  ")
+		sep, end = ",\n  ", "\n]\n"
+		buf.WriteString("[\n  ")
+	} else {
+		buf.WriteString("[")
+	}
+
+	for i := 0; i < rv.Len(); i++ {
+		if i > 0 {
+			buf.WriteString(sep)
+		}
+		if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+			return err
+		}
+		buf.Truncate(buf.Len() - 1) // drop the newline added by Encode.
+
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		buf.Reset()
+	}
+	_, err := io.WriteString(w, end)
+	return err
+}
+
+// EncodeMsgPack encodes v as MessagePack. 'json' struct tags are used for
+// the field names.
+func EncodeMsgPack(w io.Writer, _ *http.Request, v interface{}) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+var cborMode = func() cbor.EncMode {
+	em, err := cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return em
+}()
+
+// EncodeCBOR encodes v as CBOR. 'json' struct tags are used for the field
+// names unless 'cbor' tags are present.
+func EncodeCBOR(w io.Writer, _ *http.Request, v interface{}) error {
+	return cborMode.NewEncoder(w).Encode(v)
+}
+
+// EncodeXML encodes v as XML. Slices are wrapped in a 'list' element. Output
+// is indented if the request has the 'pretty' query param.
+func EncodeXML(w io.Writer, req *http.Request, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	if isPretty(req) {
+		enc.Indent("", "  ")
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+		list := xml.StartElement{Name: xml.Name{Local: "list"}}
+		if err := enc.EncodeToken(list); err != nil {
+			return err
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		if err := enc.EncodeToken(list.End()); err != nil {
+			return err
+		}
+	} else if err := enc.Encode(v); err != nil {
+		return err
+	}
+
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// EncodeYAML encodes v as YAML. Value is converted to JSON first so that
+// the 'json' struct tags, json.Marshaler implementations and field order
+// are respected.
+func EncodeYAML(w io.Writer, _ *http.Request, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	resetStyle(&node)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// EncodeText writes strings, byte slices, fmt.Stringer and errors as is and
+// other values using the '%+v' format.
+func EncodeText(w io.Writer, _ *http.Request, v interface{}) error {
+	var err error
+	switch val := v.(type) {
+	case nil:
+		return nil
+
+	case string:
+		_, err = io.WriteString(w, val)
+
+	case []byte:
+		_, err = w.Write(val)
+
+	case fmt.Stringer:
+		_, err = io.WriteString(w, val.String())
+
+	case error:
+		_, err = io.WriteString(w, val.Error())
+
+	default:
+		_, err = fmt.Fprintf(w, "%+v", v)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// resetStyle clears the JSON (flow & quoted) styles so that the node is
+// written in the block style.
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
+
+func isPretty(req *http.Request) bool {
+	if req == nil || !req.URL.Query().Has("pretty") {
+		return false
+	}
+	v := req.URL.Query().Get("pretty")
+	return v != "false" && v != "0"
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+func isJSONMarshaler(t reflect.Type) bool {
+	return t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType)
+}
+
+// shouldStream returns true if v is large enough to be written directly to
+// the response instead of being buffered.
+func shouldStream(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Slice && rv.Len() >= streamThreshold
+}
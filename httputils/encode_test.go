@@ -0,0 +1,271 @@
+package httputils_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/httputils"
+)
+
+type record struct {
+	ID   int    `json:"id" xml:"id"`
+	Name string `json:"name,omitempty" xml:"name"`
+}
+
+func TestRespond_Negotiation(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		title    string
+		accept   string
+		query    string
+		v        interface{}
+		wantType string
+		wantBody string
+	}{
+		{
+			title:    "NoAccept",
+			v:        record{ID: 1, Name: "foo"},
+			wantType: "application/json; charset=utf-8",
+			wantBody: `{"id":1,"name":"foo"}` + "\n",
+		},
+		{
+			title:    "PrettyJSON",
+			query:    "?pretty",
+			v:        record{ID: 1},
+			wantType: "application/json; charset=utf-8",
+			wantBody: "{\n  \"id\": 1\n}\n",
+		},
+		{
+			title:    "PrettySlice",
+			query:    "?pretty=true",
+			v:        []record{{ID: 1}, {ID: 2}},
+			wantType: "application/json; charset=utf-8",
+			wantBody: "[\n  {\n    \"id\": 1\n  },\n  {\n    \"id\": 2\n  }\n]\n",
+		},
+		{
+			title:    "Slice",
+			accept:   "application/json",
+			v:        []record{{ID: 1}, {ID: 2}},
+			wantType: "application/json; charset=utf-8",
+			wantBody: `[{"id":1},{"id":2}]` + "\n",
+		},
+		{
+			title:    "NilSlice",
+			v:        []record(nil),
+			wantType: "application/json; charset=utf-8",
+			wantBody: "null\n",
+		},
+		{
+			title:    "YAML",
+			accept:   "application/yaml",
+			v:        map[string]interface{}{"b": "123", "a": []int{1}},
+			wantType: "application/yaml; charset=utf-8",
+			wantBody: "a:\n  - 1\nb: \"123\"\n",
+		},
+		{
+			title:    "XML",
+			accept:   "text/html;q=0.5, application/xml",
+			v:        []record{{ID: 1, Name: "foo"}},
+			wantType: "application/xml; charset=utf-8",
+			wantBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<list><record><id>1</id><name>foo</name></record></list>` + "\n",
+		},
+		{
+			title:    "XMLUnsupported",
+			accept:   "application/xml",
+			v:        map[string]int{"a": 1},
+			wantType: "application/json; charset=utf-8",
+			wantBody: `{"a":1}` + "\n",
+		},
+		{
+			title:    "Text",
+			accept:   "text/*",
+			v:        "hello",
+			wantType: "text/plain; charset=utf-8",
+			wantBody: "hello\n",
+		},
+		{
+			title:    "TextError",
+			accept:   "text/plain",
+			v:        errors.ErrInvalid.WithFields(errors.FieldError{Field: "name", Reason: "is required"}),
+			wantType: "text/plain; charset=utf-8",
+			wantBody: "bad_request: request is not valid\n  name: is required\n",
+		},
+		{
+			title:    "XMLError",
+			accept:   "application/xml",
+			v:        errors.ErrNotFound,
+			wantType: "application/xml; charset=utf-8",
+			wantBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<error><code>not_found</code><message>Requested entity not found</message></error>` + "\n",
+		},
+		{
+			title:    "QualityWins",
+			accept:   "application/xml;q=0.5, application/yaml;q=0.8",
+			v:        record{ID: 1},
+			wantType: "application/yaml; charset=utf-8",
+			wantBody: "id: 1\n",
+		},
+		{
+			title:    "Browser",
+			accept:   "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			v:        record{ID: 1},
+			wantType: "application/json; charset=utf-8",
+			wantBody: `{"id":1}` + "\n",
+		},
+		{
+			title:    "Unknown",
+			accept:   "image/png",
+			v:        record{ID: 1},
+			wantType: "application/json; charset=utf-8",
+			wantBody: `{"id":1}` + "\n",
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.title, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			httputils.Respond(rec, req, http.StatusOK, tt.v)
+			assert.Equal(t, tt.wantType, rec.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", rec.Header().Get("Vary"))
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
+
+func TestRespond_Binary(t *testing.T) {
+	t.Parallel()
+
+	decoders := map[string]func(data []byte, v interface{}) error{
+		"application/msgpack": msgpack.Unmarshal,
+		"application/cbor":    cbor.Unmarshal,
+	}
+
+	for mediaType, decode := range decoders {
+		t.Run(mediaType, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", mediaType)
+			rec := httptest.NewRecorder()
+
+			httputils.Respond(rec, req, 0, errors.ErrInvalid.WithFields(errors.FieldError{Field: "id", Reason: "is required"}))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, mediaType, rec.Header().Get("Content-Type"))
+
+			var got map[string]interface{}
+			require.NoError(t, decode(rec.Body.Bytes(), &got))
+			assert.Equal(t, "bad_request", got["code"])
+			assert.Equal(t, "Request is not valid", got["message"])
+			assert.Len(t, got["fields"], 1)
+		})
+	}
+}
+
+func TestRespond_Stream(t *testing.T) {
+	t.Parallel()
+
+	items := make([]record, 5000)
+	for i := range items {
+		items[i].ID = i
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	wr := &countingWriter{ResponseRecorder: httptest.NewRecorder()}
+	httputils.Respond(wr, req, http.StatusOK, items)
+
+	body := wr.Body.String()
+	assert.Greater(t, wr.writes, 1)
+	assert.True(t, strings.HasPrefix(body, `[{"id":0},{"id":1},`))
+	assert.True(t, strings.HasSuffix(body, `{"id":4999}]`+"\n"))
+
+	var want bytes.Buffer
+	require.NoError(t, httputils.EncodeJSON(&want, req, items))
+	assert.Equal(t, want.String(), body)
+}
+
+func TestRespond_StreamError(t *testing.T) {
+	t.Parallel()
+
+	newItems := func(failAt int) []interface{} {
+		items := make([]interface{}, 2000)
+		for i := range items {
+			items[i] = record{ID: i}
+		}
+		items[failAt] = failingJSON{}
+		return items
+	}
+
+	t.Run("BeforeOutput", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		httputils.Respond(rec, req, http.StatusOK, newItems(0))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"internal_error"`)
+		assert.NotContains(t, rec.Body.String(), `{"id":1}`)
+	})
+
+	t.Run("MidStream", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			httputils.Respond(rec, req, http.StatusOK, newItems(1500))
+		}, "truncated response must be aborted")
+		assert.True(t, strings.HasPrefix(rec.Body.String(), `[{"id":0},`))
+	})
+
+	t.Run("MidStreamServer", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+			httputils.Respond(wr, req, http.StatusOK, newItems(1500))
+		}))
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		_, err = io.ReadAll(resp.Body)
+		assert.Error(t, err, "client must see the response as incomplete")
+	})
+}
+
+type failingJSON struct{}
+
+func (failingJSON) MarshalJSON() ([]byte, error) { return nil, io.ErrUnexpectedEOF }
+
+func TestRegisterEncoder(t *testing.T) {
+	// not parallel: modifies the global registry.
+	httputils.RegisterEncoder("application/vnd.test", func(w io.Writer, _ *http.Request, v interface{}) error {
+		_, err := io.WriteString(w, "test")
+		return err
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/vnd.test")
+	rec := httptest.NewRecorder()
+	httputils.Respond(rec, req, http.StatusOK, record{})
+	assert.Equal(t, "application/vnd.test", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "test", rec.Body.String())
+}
+
+type countingWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.writes++
+	return cw.ResponseRecorder.Write(p)
+}
@@ -0,0 +1,144 @@
+package httputils
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+
+	"github.com/spy16/moonshot/errors"
+)
+
+// Operation describes a handler for generating the API documentation
+// (see openapi package).
+type Operation struct {
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+
+	// Request and Response are the types of the decoded request and the
+	// response body. Nil if the handler does not accept or write a body.
+	Request  reflect.Type
+	Response reflect.Type
+
+	// Status is the status code of successful responses.
+	Status int
+
+	// Errors are the kinds of errors the handler may respond with.
+	Errors []errors.Error
+}
+
+// Describer is implemented by handlers that can describe themselves for
+// generating the API documentation. Handlers created by JSON() implement
+// this. Use Describe() for others.
+type Describer interface {
+	Describe() Operation
+}
+
+// Describe returns a handler that serves using h and describes itself
+// using op.
+func Describe(h http.Handler, op Operation) http.Handler {
+	return &describedHandler{Handler: h, op: op}
+}
+
+type describedHandler struct {
+	http.Handler
+	op Operation
+}
+
+func (dh *describedHandler) Describe() Operation { return dh.op }
+
+// HandlerOption can be passed to JSON() to customise the handler.
+type HandlerOption func(opts *handlerOptions)
+
+type handlerOptions struct {
+	status int
+	op     Operation
+}
+
+// WithStatus sets the status code used for successful responses. Defaults
+// to 200. Response body is not written for 204.
+func WithStatus(status int) HandlerOption {
+	return func(opts *handlerOptions) { opts.status = status }
+}
+
+// WithSummary sets the summary and description of the operation in API
+// documentation.
+func WithSummary(summary, description string) HandlerOption {
+	return func(opts *handlerOptions) {
+		opts.op.Summary = summary
+		opts.op.Description = description
+	}
+}
+
+// WithTags sets the tags used for grouping the operation in the API
+// documentation.
+func WithTags(tags ...string) HandlerOption {
+	return func(opts *handlerOptions) { opts.op.Tags = append(opts.op.Tags, tags...) }
+}
+
+// WithErrors documents the kinds of errors (e.g., errors.ErrNotFound) the
+// operation may respond with.
+func WithErrors(errs ...errors.Error) HandlerOption {
+	return func(opts *handlerOptions) { opts.op.Errors = append(opts.op.Errors, errs...) }
+}
+
+// WithDeprecated marks the operation as deprecated in the documentation.
+func WithDeprecated() HandlerOption {
+	return func(opts *handlerOptions) { opts.op.Deprecated = true }
+}
+
+// JSON returns an http.Handler that decodes the request into Req (see
+// Decode), validates it (see Validate), invokes fn and writes the result
+// using Respond. Errors returned by fn are mapped to status codes using
+// ErrorStatus.
+//
+//	router.Method(http.MethodPost, "/users/{org}", httputils.JSON(svc.CreateUser,
+//		httputils.WithStatus(http.StatusCreated)))
+func JSON[Req, Resp any](fn func(ctx context.Context, req Req) (Resp, error), opts ...HandlerOption) http.Handler {
+	o := handlerOptions{status: http.StatusOK}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &jsonHandler[Req, Resp]{fn: fn, opts: o}
+}
+
+type jsonHandler[Req, Resp any] struct {
+	fn   func(ctx context.Context, req Req) (Resp, error)
+	opts handlerOptions
+}
+
+func (h *jsonHandler[Req, Resp]) Describe() Operation {
+	op := h.opts.op
+	op.Status = h.opts.status
+	op.Request = reflect.TypeOf((*Req)(nil)).Elem()
+	if op.Status != http.StatusNoContent {
+		op.Response = reflect.TypeOf((*Resp)(nil)).Elem()
+	}
+	return op
+}
+
+func (h *jsonHandler[Req, Resp]) ServeHTTP(wr http.ResponseWriter, req *http.Request) {
+	var in Req
+	if err := Decode(req, &in); err != nil {
+		Respond(wr, req, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := Validate(&in); err != nil {
+		Respond(wr, req, http.StatusBadRequest, err)
+		return
+	}
+
+	out, err := h.fn(req.Context(), in)
+	if err != nil {
+		Respond(wr, req, http.StatusInternalServerError, err)
+		return
+	}
+
+	if h.opts.status == http.StatusNoContent {
+		wr.WriteHeader(http.StatusNoContent)
+		return
+	}
+	Respond(wr, req, h.opts.status, out)
+}
@@ -0,0 +1,221 @@
+package httputils_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/httputils"
+)
+
+type createUserReq struct {
+	Org     string        `json:"-" path:"org" validate:"required"`
+	DryRun  bool          `json:"-" query:"dry_run"`
+	Tags    []string      `json:"-" query:"tag"`
+	Timeout time.Duration `json:"-" header:"X-Timeout"`
+	Name    string        `json:"name" form:"name" validate:"required,min=2"`
+	Email   string        `json:"email" form:"email" validate:"email"`
+	Age     *int          `json:"age" validate:"min=18"`
+	Role    string        `json:"role" validate:"oneof=admin member"`
+}
+
+func (r createUserReq) Validate() error {
+	if r.Role == "admin" && r.Org != "root" {
+		return errors.ErrForbidden.WithMsgf("admins can be created only in root org")
+	}
+	return nil
+}
+
+type createUserResp struct {
+	ID      string   `json:"id"`
+	Org     string   `json:"org"`
+	Name    string   `json:"name"`
+	DryRun  bool     `json:"dry_run"`
+	Tags    []string `json:"tags"`
+	Timeout string   `json:"timeout"`
+}
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+
+	createUser := func(ctx context.Context, req createUserReq) (createUserResp, error) {
+		if req.Name == "taken" {
+			return createUserResp{}, errors.ErrConflict.WithMsgf("name is taken")
+		}
+		return createUserResp{
+			ID:      "u1",
+			Org:     req.Org,
+			Name:    req.Name,
+			DryRun:  req.DryRun,
+			Tags:    req.Tags,
+			Timeout: req.Timeout.String(),
+		}, nil
+	}
+
+	router := chi.NewRouter()
+	router.Method(http.MethodPost, "/orgs/{org}/users",
+		httputils.JSON(createUser, httputils.WithStatus(http.StatusCreated)))
+
+	table := []struct {
+		title      string
+		target     string
+		ct         string
+		body       string
+		header     http.Header
+		wantStatus int
+		wantBody   map[string]interface{}
+	}{
+		{
+			title:      "AllSources",
+			target:     "/orgs/acme/users?dry_run=true&tag=a&tag=b",
+			body:       `{"name": "bob", "email": "bob@example.com", "role": "member"}`,
+			header:     http.Header{"X-Timeout": {"5s"}},
+			wantStatus: http.StatusCreated,
+			wantBody: map[string]interface{}{
+				"id": "u1", "org": "acme", "name": "bob", "dry_run": true,
+				"tags": []interface{}{"a", "b"}, "timeout": "5s",
+			},
+		},
+		{
+			title:      "Form",
+			target:     "/orgs/acme/users",
+			ct:         "application/x-www-form-urlencoded",
+			body:       url.Values{"name": {"alice"}}.Encode(),
+			wantStatus: http.StatusCreated,
+			wantBody: map[string]interface{}{
+				"id": "u1", "org": "acme", "name": "alice", "dry_run": false,
+				"tags": nil, "timeout": "0s",
+			},
+		},
+		{
+			title:      "ValidationFailure",
+			target:     "/orgs/acme/users",
+			body:       `{"name": "b", "email": "not-an-email", "age": 10, "role": "owner"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: map[string]interface{}{
+				"code":    "bad_request",
+				"message": "Request is not valid",
+				"fields": []interface{}{
+					map[string]interface{}{"field": "name", "reason": "length must be at least 2"},
+					map[string]interface{}{"field": "email", "reason": "must be a valid email address"},
+					map[string]interface{}{"field": "age", "reason": "value must be at least 18"},
+					map[string]interface{}{"field": "role", "reason": "must be one of [admin, member]"},
+				},
+			},
+		},
+		{
+			title:      "InvalidQueryParam",
+			target:     "/orgs/acme/users?dry_run=maybe",
+			body:       `{"name": "bob"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: map[string]interface{}{
+				"code":    "bad_request",
+				"message": "Request is not valid",
+				"fields": []interface{}{
+					map[string]interface{}{"field": "dry_run", "reason": "must be a boolean"},
+				},
+			},
+		},
+		{
+			title:      "JSONTypeMismatch",
+			target:     "/orgs/acme/users",
+			body:       `{"name": 10}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: map[string]interface{}{
+				"code":    "bad_request",
+				"message": "Request is not valid",
+				"fields": []interface{}{
+					map[string]interface{}{"field": "name", "reason": "must be of type string"},
+				},
+			},
+		},
+		{
+			title:      "MalformedJSON",
+			target:     "/orgs/acme/users",
+			body:       `{"name": `,
+			wantStatus: http.StatusBadRequest,
+			wantBody: map[string]interface{}{
+				"code":    "bad_request",
+				"message": "request body is not valid JSON",
+			},
+		},
+		{
+			title:      "UnsupportedContentType",
+			target:     "/orgs/acme/users",
+			ct:         "text/csv",
+			body:       `name\nbob`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: map[string]interface{}{
+				"code":    "bad_request",
+				"message": "unsupported content-type 'text/csv'",
+			},
+		},
+		{
+			title:      "CustomValidator",
+			target:     "/orgs/acme/users",
+			body:       `{"name": "bob", "role": "admin"}`,
+			wantStatus: http.StatusForbidden,
+			wantBody: map[string]interface{}{
+				"code":    "forbidden",
+				"message": "admins can be created only in root org",
+			},
+		},
+		{
+			title:      "ServiceError",
+			target:     "/orgs/acme/users",
+			body:       `{"name": "taken"}`,
+			wantStatus: http.StatusConflict,
+			wantBody: map[string]interface{}{
+				"code":    "conflict",
+				"message": "name is taken",
+			},
+		},
+	}
+
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.title, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			if tt.ct != "" {
+				req.Header.Set("Content-Type", tt.ct)
+			}
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+
+			var got map[string]interface{}
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+			assert.Equal(t, tt.wantBody, got)
+		})
+	}
+}
+
+func TestJSON_NoContent(t *testing.T) {
+	t.Parallel()
+
+	h := httputils.JSON(func(ctx context.Context, req *struct{}) (interface{}, error) {
+		return nil, nil
+	}, httputils.WithStatus(http.StatusNoContent))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/", nil))
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
@@ -0,0 +1,33 @@
+package httputils
+
+import (
+	"context"
+
+	"github.com/spy16/moonshot/log"
+)
+
+var identityKey = ctxKey("identity")
+
+// Identity represents the authenticated principal of a request. Auth
+// middlewares should set it using WithIdentity() so that the other
+// components (e.g., rate limiter) can make use of it.
+type Identity struct {
+	// Subject uniquely identifies the principal (e.g., user ID).
+	Subject string
+
+	// Attrs holds any additional attributes (e.g., roles, issuer).
+	Attrs map[string]string
+}
+
+// WithIdentity returns a new context with the identity set. Subject is
+// also added to the log fields as 'subject'.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	ctx = context.WithValue(ctx, identityKey, id)
+	return log.InjectFields(ctx, log.Fields{"subject": id.Subject})
+}
+
+// IdentityFrom returns the identity stored in the context, if any.
+func IdentityFrom(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey).(Identity)
+	return id, ok
+}
@@ -0,0 +1,180 @@
+package httputils
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/spy16/moonshot/errors"
+)
+
+// Timeout is a middleware that sets a deadline of 'd' on the request
+// context and responds with ErrTimeout if the handler does not write a
+// response before the deadline. Writes by the handler after the timeout
+// fail with http.ErrHandlerTimeout. Timeout can be applied per route
+// using chi's With(), but nested timeouts can only shorten the deadline.
+// It should not be used for streaming or websocket routes.
+func Timeout(d time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+			ctx, cancel := context.WithTimeout(req.Context(), d)
+			defer cancel()
+			req = req.WithContext(ctx)
+
+			tw := &timeoutWriter{wr: wr, h: wr.Header().Clone()}
+			done := make(chan struct{})
+			panicCh := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						if p != http.ErrAbortHandler {
+							p = stackPanic{value: p, stack: debug.Stack()}
+						}
+						panicCh <- p
+					}
+				}()
+				next.ServeHTTP(tw, req)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicCh:
+				panic(p)
+
+			case <-done:
+
+			case <-ctx.Done():
+				tw.mu.Lock()
+				if !tw.wroteHeader && ctx.Err() == context.DeadlineExceeded {
+					tw.timedOut = true
+					tw.mu.Unlock()
+					Respond(wr, req, http.StatusGatewayTimeout,
+						errors.ErrTimeout.WithCausef("handler did not respond within %s", d))
+					return
+				}
+				tw.mu.Unlock()
+
+				// response is partially written already. the handler must
+				// finish before we can return.
+				select {
+				case p := <-panicCh:
+					panic(p)
+				case <-done:
+				}
+			}
+		})
+	}
+}
+
+// stackPanic carries the stack trace of panics recovered in goroutines
+// spawned by middlewares so that Recover can log the original stack.
+type stackPanic struct {
+	value interface{}
+	stack []byte
+}
+
+// timeoutWriter guards the response writer so that the handler cannot
+// write after the timeout response is sent.
+type timeoutWriter struct {
+	mu          sync.Mutex
+	wr          http.ResponseWriter
+	h           http.Header
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.h }
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeader(status)
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeader(http.StatusOK)
+	return tw.wr.Write(p)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return
+	}
+	tw.writeHeader(http.StatusOK)
+	if f, ok := tw.wr.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (tw *timeoutWriter) writeHeader(status int) {
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+
+	dst := tw.wr.Header()
+	for k := range dst {
+		if _, found := tw.h[k]; !found {
+			delete(dst, k)
+		}
+	}
+	for k, v := range tw.h {
+		dst[k] = v
+	}
+	tw.wr.WriteHeader(status)
+}
+
+// BodyLimit is a middleware that limits the size of request body to the
+// given number of bytes. Reads fail with *http.MaxBytesError (which is
+// mapped to ErrTooLarge by Respond) when the limit is exceeded, or on the
+// first read itself if the Content-Length is larger than the limit.
+// BodyLimit applied per route (e.g., using chi's With()) overrides the
+// limit set by the outer ones.
+func BodyLimit(maxBytes int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+			body := req.Body
+			if lb, ok := body.(*limitedBody); ok {
+				body = lb.orig
+			}
+
+			if body != nil && body != http.NoBody {
+				req.Body = &limitedBody{
+					ReadCloser: http.MaxBytesReader(wr, body, maxBytes),
+					orig:       body,
+					limit:      maxBytes,
+					tooLarge:   req.ContentLength > maxBytes,
+				}
+			}
+			next.ServeHTTP(wr, req)
+		})
+	}
+}
+
+// limitedBody retains the original body so that the limit can be
+// overridden by inner BodyLimit middlewares.
+type limitedBody struct {
+	io.ReadCloser
+	orig     io.ReadCloser
+	limit    int64
+	tooLarge bool
+}
+
+func (lb *limitedBody) Read(p []byte) (int, error) {
+	if lb.tooLarge {
+		return 0, &http.MaxBytesError{Limit: lb.limit}
+	}
+	return lb.ReadCloser.Read(p)
+}
@@ -0,0 +1,134 @@
+package httputils_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/httputils"
+	"github.com/spy16/moonshot/log"
+	"github.com/spy16/moonshot/log/logtest"
+)
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Slow", func(t *testing.T) {
+		t.Parallel()
+
+		lateWrite := make(chan error, 1)
+		h := httputils.Timeout(20 * time.Millisecond)(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+			<-req.Context().Done()
+			time.Sleep(10 * time.Millisecond)
+			wr.Header().Set("X-Late", "true")
+			_, err := wr.Write([]byte("late"))
+			lateWrite <- err
+		}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"timeout"`)
+		assert.Equal(t, http.ErrHandlerTimeout, <-lateWrite)
+		assert.Empty(t, rec.Header().Get("X-Late"))
+	})
+
+	t.Run("Fast", func(t *testing.T) {
+		t.Parallel()
+
+		h := httputils.Timeout(time.Second)(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+			_, hasDeadline := req.Context().Deadline()
+			assert.True(t, hasDeadline)
+
+			wr.Header().Set("X-Custom", "yes")
+			wr.WriteHeader(http.StatusCreated)
+			_, _ = wr.Write([]byte("ok"))
+		}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "yes", rec.Header().Get("X-Custom"))
+		assert.Equal(t, "ok", rec.Body.String())
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, logs := logtest.Capture(t)
+		h := httputils.Recover(httputils.Timeout(time.Second)(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+			panic("boom")
+		})))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		entries := logs.Filter(log.ErrorLevel)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "boom", entries[0].Fields["panic"])
+		assert.Contains(t, entries[0].Fields["stack"], "limits_test.go")
+	})
+}
+
+func TestBodyLimit(t *testing.T) {
+	t.Parallel()
+
+	echo := http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			httputils.Respond(wr, req, http.StatusBadRequest, err)
+			return
+		}
+		_, _ = wr.Write(data)
+	})
+
+	router := chi.NewRouter()
+	router.Use(httputils.BodyLimit(8))
+	router.Post("/default", echo)
+	router.With(httputils.BodyLimit(32)).Post("/upload", echo)
+
+	table := []struct {
+		title      string
+		path       string
+		body       string
+		unknownLen bool
+		wantStatus int
+	}{
+		{title: "WithinLimit", path: "/default", body: "small", wantStatus: http.StatusOK},
+		{title: "ContentLengthTooLarge", path: "/default", body: "this is too large", wantStatus: http.StatusRequestEntityTooLarge},
+		{title: "StreamTooLarge", path: "/default", body: "this is too large", unknownLen: true, wantStatus: http.StatusRequestEntityTooLarge},
+		{title: "RouteOverride", path: "/upload", body: "this is larger than default", wantStatus: http.StatusOK},
+		{title: "RouteOverrideTooLarge", path: "/upload", body: strings.Repeat("x", 64), wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.title, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.unknownLen {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, tt.body, rec.Body.String())
+			} else {
+				assert.Contains(t, rec.Body.String(), `"too_large"`)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package httputils
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	schemeUnix    = "unix://"
+	schemeSystemd = "systemd://"
+
+	// DefaultSocketMode is the file mode of the Unix sockets created by
+	// Listen.
+	DefaultSocketMode os.FileMode = 0o660
+)
+
+// ListenOption customises the listeners created by Listen.
+type ListenOption func(opts *listenOptions)
+
+type listenOptions struct {
+	socketMode os.FileMode
+}
+
+// WithSocketMode sets the file mode of the Unix socket.
+func WithSocketMode(mode os.FileMode) ListenOption {
+	return func(opts *listenOptions) { opts.socketMode = mode }
+}
+
+// Listen returns a listener for the address. Supported formats:
+//
+//   - 'host:port' for TCP.
+//   - 'unix:///path/to/app.sock' for Unix socket. Stale socket file left
+//     by a previous run is removed and the file mode is set to
+//     DefaultSocketMode unless overridden using WithSocketMode.
+//   - 'systemd://' or 'systemd://<name>' for the socket passed by systemd
+//     socket activation (LISTEN_FDS). Name is the 'FileDescriptorName' of
+//     the socket unit. First socket is used if the name is not given.
+func Listen(addr string, opts ...ListenOption) (net.Listener, error) {
+	lo := listenOptions{socketMode: DefaultSocketMode}
+	for _, opt := range opts {
+		opt(&lo)
+	}
+
+	switch {
+	case strings.HasPrefix(addr, schemeUnix):
+		return listenUnix(strings.TrimPrefix(addr, schemeUnix), lo.socketMode)
+
+	case strings.HasPrefix(addr, schemeSystemd):
+		return activated.take(strings.TrimPrefix(addr, schemeSystemd))
+
+	default:
+		return net.Listen("tcp", addr)
+	}
+}
+
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if path == "" {
+		return nil, fmt.Errorf("unix socket path is required")
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	// socket is created in a private directory and moved into place after
+	// setting the mode so that it is never reachable with the permissions
+	// derived from the umask.
+	tmpDir, err := os.MkdirTemp(filepath.Dir(path), ".sock-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	tmpPath := filepath.Join(tmpDir, "s")
+	lis, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmpPath, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	lis.SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		_ = lis.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = lis.Close()
+		return nil, err
+	}
+	return &unixListener{UnixListener: lis, path: path}, nil
+}
+
+// unixListener removes the socket file on close. net.UnixListener cannot
+// do it since the socket is renamed after creation.
+type unixListener struct {
+	*net.UnixListener
+	path string
+	once sync.Once
+}
+
+func (ul *unixListener) Close() error {
+	err := ul.UnixListener.Close()
+	ul.once.Do(func() { _ = os.Remove(ul.path) })
+	return err
+}
+
+// removeStaleSocket removes the socket file if no process is listening on
+// it (e.g., left behind by a crash). The file is kept if it cannot be
+// determined (e.g., permission denied or timeout).
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("'%s' exists and is not a socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("socket '%s' is in use by another process", path)
+	} else if !stderrors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("socket '%s' exists and cannot be checked: %w", path, err)
+	}
+	return os.Remove(path)
+}
+
+// activated holds the listeners passed by systemd. Each can be taken only
+// once.
+var activated = &activatedListeners{}
+
+type activatedListeners struct {
+	once  sync.Once
+	mu    sync.Mutex
+	err   error
+	names []string
+	lis   []net.Listener
+}
+
+func (al *activatedListeners) take(name string) (net.Listener, error) {
+	al.once.Do(al.load)
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	if al.err != nil {
+		return nil, al.err
+	}
+
+	for i, lis := range al.lis {
+		if lis != nil && (name == "" || al.names[i] == name) {
+			al.lis[i] = nil
+			return lis, nil
+		}
+	}
+	return nil, fmt.Errorf("systemd: no socket available with name '%s'", name)
+}
+
+// load creates the listeners from the file descriptors passed by systemd
+// (starting at 3) and clears the environment variables so that the child
+// processes do not inherit them.
+func (al *activatedListeners) load() {
+	const firstFD = 3
+
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		al.err = fmt.Errorf("systemd: no sockets passed (LISTEN_PID not set for this process)")
+		return
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		al.err = fmt.Errorf("systemd: no sockets passed (invalid LISTEN_FDS)")
+		return
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for i := 0; i < count; i++ {
+		name := "LISTEN_FD_" + strconv.Itoa(firstFD+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(firstFD+i), name)
+		lis, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			for _, l := range al.lis {
+				_ = l.Close()
+			}
+			al.lis, al.names = nil, nil
+			al.err = fmt.Errorf("systemd: socket '%s': %w", name, err)
+			return
+		}
+		al.names = append(al.names, name)
+		al.lis = append(al.lis, lis)
+	}
+}
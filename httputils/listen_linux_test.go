@@ -0,0 +1,43 @@
+package httputils_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/httputils"
+)
+
+func TestListen_UnixBusy(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "app.sock")
+
+	// listener with a full backlog refuses the connections with EAGAIN
+	// instead of ECONNREFUSED.
+	fd, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	defer syscall.Close(fd)
+	require.NoError(t, syscall.Bind(fd, &syscall.SockaddrUnix{Name: path}))
+	require.NoError(t, syscall.Listen(fd, 0))
+
+	for i := 0; ; i++ {
+		require.Less(t, i, 16, "backlog must be full")
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			break
+		}
+		defer conn.Close()
+	}
+
+	_, err = httputils.Listen("unix://" + path)
+	assert.Error(t, err)
+
+	_, err = os.Stat(path)
+	assert.NoError(t, err, "socket file in use must not be removed")
+}
@@ -0,0 +1,98 @@
+package httputils_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/httputils"
+)
+
+func TestListen_TCP(t *testing.T) {
+	t.Parallel()
+
+	lis, err := httputils.Listen("localhost:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	assert.Equal(t, "tcp", lis.Addr().Network())
+}
+
+func TestListen_Unix(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("unix socket file modes are not supported on windows")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.sock")
+
+	// socket file left behind by a crashed process.
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	lis, err := httputils.Listen("unix://"+path, httputils.WithSocketMode(0o600))
+	require.NoError(t, err)
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	t.Run("InUse", func(t *testing.T) {
+		_, err := httputils.Listen("unix://" + path)
+		assert.Error(t, err)
+	})
+
+	t.Run("NotSocket", func(t *testing.T) {
+		file := filepath.Join(dir, "file.txt")
+		require.NoError(t, os.WriteFile(file, []byte("hello"), 0o600))
+
+		_, err := httputils.Listen("unix://" + file)
+		assert.Error(t, err)
+	})
+
+	t.Run("Serve", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() {
+			served <- httputils.GracefulServe(ctx, time.Second, lis, http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+				_, _ = io.WriteString(wr, "ok")
+			}))
+		}()
+
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}}
+		resp, err := client.Get("http://app/")
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, "ok", string(body))
+
+		cancel()
+		assert.NoError(t, <-served)
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err), "socket file must be removed on close")
+	})
+}
+
+func TestListen_Systemd(t *testing.T) {
+	t.Parallel()
+
+	_, err := httputils.Listen("systemd://")
+	assert.Error(t, err)
+}
@@ -0,0 +1,310 @@
+package httputils
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/log"
+)
+
+// KeyFunc extracts the rate limit key from the request. Requests for
+// which ok is false are not rate limited.
+type KeyFunc func(req *http.Request) (key string, ok bool)
+
+// Limiter decides whether a request identified by the key is allowed.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (RateLimitResult, error)
+}
+
+// RateLimitResult represents the outcome of a Limiter.Allow() call.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// RateLimitStore persists the limiter state. Implementations backed by
+// shared stores (e.g., Redis) must apply the update atomically across all
+// instances (e.g., using optimistic locking).
+type RateLimitStore interface {
+	// Update loads the state for the key (zero value if not found), applies
+	// fn and saves the returned state with the given ttl.
+	Update(ctx context.Context, key string, ttl time.Duration,
+		fn func(cur RateLimitState) RateLimitState) (RateLimitState, error)
+}
+
+// RateLimitState is the state maintained by the limiters per key.
+type RateLimitState struct {
+	Stamp     time.Time `json:"stamp"`
+	Tokens    float64   `json:"tokens,omitempty"`
+	Count     int64     `json:"count,omitempty"`
+	PrevCount int64     `json:"prev_count,omitempty"`
+}
+
+// RateLimitOptions controls the behaviour of the rate limit middleware.
+type RateLimitOptions struct {
+	Limiter Limiter
+
+	// Key extracts the key from request. Defaults to KeyByIP.
+	Key KeyFunc
+}
+
+// RateLimit returns a middleware that limits the requests using the
+// limiter. Rate limited requests are responded with ErrRateLimited along
+// with 'RateLimit-*' and 'Retry-After' headers. Requests are allowed if
+// the limiter fails (e.g., store is unreachable).
+func RateLimit(opts RateLimitOptions) func(next http.Handler) http.Handler {
+	if opts.Key == nil {
+		opts.Key = KeyByIP
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+			key, ok := opts.Key(req)
+			if !ok {
+				next.ServeHTTP(wr, req)
+				return
+			}
+
+			res, err := opts.Limiter.Allow(req.Context(), key)
+			if err != nil {
+				log.Warn(req.Context(), "rate limiter failed, allowing request", "err", err)
+				next.ServeHTTP(wr, req)
+				return
+			}
+
+			h := wr.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+			if !res.Allowed {
+				Respond(wr, req, http.StatusTooManyRequests,
+					errors.ErrRateLimited.WithRetryAfter(res.RetryAfter))
+				return
+			}
+			next.ServeHTTP(wr, req)
+		})
+	}
+}
+
+// KeyByIP uses the remote IP of the request as the key. Use RealIP with
+// the trusted proxies before this when running behind proxies.
+func KeyByIP(req *http.Request) (string, bool) {
+	return "ip:" + remoteIP(req), true
+}
+
+// KeyByHeader returns a KeyFunc that uses the value of the header (e.g.,
+// 'X-API-Key') as the key. Requests without the header are not limited.
+func KeyByHeader(name string) KeyFunc {
+	return func(req *http.Request) (string, bool) {
+		v := req.Header.Get(name)
+		return "header:" + name + ":" + v, v != ""
+	}
+}
+
+// KeyByUser uses the subject of the identity (see WithIdentity) as the
+// key. Unauthenticated requests are not limited.
+func KeyByUser(req *http.Request) (string, bool) {
+	id, ok := IdentityFrom(req.Context())
+	if !ok || id.Subject == "" {
+		return "", false
+	}
+	return "user:" + id.Subject, true
+}
+
+// NewTokenBucket returns a token bucket limiter that allows 'limit'
+// requests per 'per' duration on average with bursts of up to 'burst'
+// requests. If burst is not positive, it is set to limit.
+func NewTokenBucket(limit int, per time.Duration, burst int, store RateLimitStore) Limiter {
+	if burst <= 0 {
+		burst = limit
+	}
+	return &tokenBucket{
+		limit: limit,
+		burst: burst,
+		rate:  float64(limit) / per.Seconds(),
+		store: store,
+		now:   time.Now,
+	}
+}
+
+type tokenBucket struct {
+	limit int
+	burst int
+	rate  float64 // tokens per second
+	store RateLimitStore
+	now   func() time.Time
+}
+
+func (tb *tokenBucket) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	now := tb.now()
+	capacity := float64(tb.burst)
+	ttl := time.Duration(capacity / tb.rate * float64(time.Second))
+
+	allowed := false
+	state, err := tb.store.Update(ctx, key, ttl, func(cur RateLimitState) RateLimitState {
+		tokens := capacity
+		if !cur.Stamp.IsZero() {
+			elapsed := now.Sub(cur.Stamp).Seconds()
+			tokens = math.Min(capacity, cur.Tokens+math.Max(0, elapsed)*tb.rate)
+		}
+
+		allowed = tokens >= 1
+		if allowed {
+			tokens--
+		}
+		return RateLimitState{Stamp: now, Tokens: tokens}
+	})
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	res := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     tb.burst,
+		Remaining: int(state.Tokens),
+		Reset:     secondsToDur((capacity - state.Tokens) / tb.rate),
+	}
+	if !allowed {
+		res.RetryAfter = secondsToDur((1 - state.Tokens) / tb.rate)
+	}
+	return res, nil
+}
+
+// NewSlidingWindow returns a limiter that allows 'limit' requests in any
+// 'window' duration. It uses the sliding window counter approximation
+// where the count of the previous window is weighted by its overlap.
+func NewSlidingWindow(limit int, window time.Duration, store RateLimitStore) Limiter {
+	return &slidingWindow{
+		limit:  limit,
+		window: window,
+		store:  store,
+		now:    time.Now,
+	}
+}
+
+type slidingWindow struct {
+	limit  int
+	window time.Duration
+	store  RateLimitStore
+	now    func() time.Time
+}
+
+func (sw *slidingWindow) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	now := sw.now()
+	start := now.Truncate(sw.window)
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(sw.window)
+
+	var estimate float64
+	allowed := false
+	state, err := sw.store.Update(ctx, key, 2*sw.window, func(cur RateLimitState) RateLimitState {
+		if !cur.Stamp.Equal(start) {
+			prev := int64(0)
+			if cur.Stamp.Equal(start.Add(-sw.window)) {
+				prev = cur.Count
+			}
+			cur = RateLimitState{Stamp: start, PrevCount: prev}
+		}
+
+		estimate = float64(cur.PrevCount)*weight + float64(cur.Count)
+		allowed = estimate+1 <= float64(sw.limit)
+		if allowed {
+			cur.Count++
+			estimate++
+		}
+		return cur
+	})
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	res := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     sw.limit,
+		Remaining: int(math.Max(0, float64(sw.limit)-math.Ceil(estimate))),
+		Reset:     sw.window - elapsed,
+	}
+	if !allowed {
+		res.RetryAfter = sw.retryAfter(state, elapsed)
+	}
+	return res, nil
+}
+
+// retryAfter estimates the time after which the weighted count drops
+// enough to allow one more request.
+func (sw *slidingWindow) retryAfter(state RateLimitState, elapsed time.Duration) time.Duration {
+	room := float64(sw.limit-1) - float64(state.Count)
+	if room < 0 || state.PrevCount == 0 {
+		return sw.window - elapsed
+	}
+
+	// solve: prev * (1 - e/window) <= room for e.
+	need := time.Duration((1 - room/float64(state.PrevCount)) * float64(sw.window))
+	if need <= elapsed {
+		return 0
+	}
+	return need - elapsed
+}
+
+// NewMemoryStore returns an in-memory RateLimitStore. It is suitable only
+// for single instance deployments.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: map[string]memItem{}, now: time.Now}
+}
+
+// MemoryStore is an in-memory RateLimitStore. Expired entries are removed
+// periodically during updates.
+type MemoryStore struct {
+	mu      sync.Mutex
+	items   map[string]memItem
+	updates int
+	now     func() time.Time
+}
+
+type memItem struct {
+	state     RateLimitState
+	expiresAt time.Time
+}
+
+// Update applies fn on the current state of the key and saves the result.
+func (ms *MemoryStore) Update(_ context.Context, key string, ttl time.Duration,
+	fn func(cur RateLimitState) RateLimitState) (RateLimitState, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	ms.updates++
+	if ms.updates%1000 == 0 {
+		for k, item := range ms.items {
+			if now.After(item.expiresAt) {
+				delete(ms.items, k)
+			}
+		}
+	}
+
+	item, found := ms.items[key]
+	if !found || now.After(item.expiresAt) {
+		item = memItem{}
+	}
+
+	next := fn(item.state)
+	ms.items[key] = memItem{state: next, expiresAt: now.Add(ttl)}
+	return next, nil
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func secondsToDur(secs float64) time.Duration {
+	return time.Duration(secs * float64(time.Second))
+}
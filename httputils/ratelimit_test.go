@@ -0,0 +1,158 @@
+package httputils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (fc *fakeClock) now() time.Time          { return fc.t }
+func (fc *fakeClock) advance(d time.Duration) { fc.t = fc.t.Add(d) }
+
+func TestTokenBucket(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	store := NewMemoryStore()
+	store.now = clock.now
+	tb := NewTokenBucket(2, time.Second, 3, store).(*tokenBucket)
+	tb.now = clock.now
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		res, err := tb.Allow(ctx, "k")
+		require.NoError(t, err)
+		assert.True(t, res.Allowed, "request %d", i)
+		assert.Equal(t, 3, res.Limit)
+		assert.Equal(t, 2-i, res.Remaining)
+	}
+
+	res, err := tb.Allow(ctx, "k")
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, res.Reset)
+
+	// other keys are not affected.
+	res, err = tb.Allow(ctx, "other")
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	clock.advance(500 * time.Millisecond)
+	res, err = tb.Allow(ctx, "k")
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	// bucket never fills beyond burst.
+	clock.advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		res, _ = tb.Allow(ctx, "k")
+		assert.True(t, res.Allowed)
+	}
+	res, _ = tb.Allow(ctx, "k")
+	assert.False(t, res.Allowed)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	store := NewMemoryStore()
+	store.now = clock.now
+	sw := NewSlidingWindow(4, 10*time.Second, store).(*slidingWindow)
+	sw.now = clock.now
+
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		res, err := sw.Allow(ctx, "k")
+		require.NoError(t, err)
+		assert.True(t, res.Allowed, "request %d", i)
+		assert.Equal(t, 3-i, res.Remaining)
+	}
+
+	res, err := sw.Allow(ctx, "k")
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 10*time.Second, res.RetryAfter)
+
+	// half way into the next window, previous window contributes 2.
+	clock.advance(15 * time.Second)
+	for i := 0; i < 2; i++ {
+		res, _ = sw.Allow(ctx, "k")
+		assert.True(t, res.Allowed, "request %d", i)
+	}
+	res, _ = sw.Allow(ctx, "k")
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 2500*time.Millisecond, res.RetryAfter)
+	assert.Equal(t, 5*time.Second, res.Reset)
+
+	clock.advance(2500 * time.Millisecond)
+	res, _ = sw.Allow(ctx, "k")
+	assert.True(t, res.Allowed)
+
+	// windows older than the previous one are ignored.
+	clock.advance(time.Minute)
+	res, _ = sw.Allow(ctx, "k")
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 3, res.Remaining)
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		wr.WriteHeader(http.StatusNoContent)
+	})
+	h := RateLimit(RateLimitOptions{
+		Limiter: NewTokenBucket(1, time.Minute, 1, NewMemoryStore()),
+		Key:     KeyByHeader("X-API-Key"),
+	})(next)
+
+	send := func(apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send("a")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", rec.Header().Get("RateLimit-Reset"))
+
+	rec = send("a")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), `"rate_limited"`)
+
+	assert.Equal(t, http.StatusNoContent, send("b").Code)
+
+	// requests without key are not limited.
+	assert.Equal(t, http.StatusNoContent, send("").Code)
+	assert.Equal(t, http.StatusNoContent, send("").Code)
+}
+
+func TestKeyByUser(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, ok := KeyByUser(req)
+	assert.False(t, ok)
+
+	req = req.WithContext(WithIdentity(req.Context(), Identity{Subject: "u1"}))
+	key, ok := KeyByUser(req)
+	assert.True(t, ok)
+	assert.Equal(t, "user:u1", key)
+}
@@ -0,0 +1,138 @@
+package httputils
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+var peerAddrKey = ctxKey("peer_addr")
+
+// RealIPOptions controls the behaviour of the RealIP middleware.
+type RealIPOptions struct {
+	// TrustedProxies are the networks of the proxies allowed to set the
+	// client IP using the 'X-Forwarded-For' and 'X-Real-IP' headers.
+	// Requests over Unix sockets are from local processes and are always
+	// trusted.
+	TrustedProxies []netip.Prefix
+}
+
+// RealIP is a middleware that sets the request's RemoteAddr to the client
+// IP from the 'X-Forwarded-For' (right-most untrusted address) or the
+// 'X-Real-IP' headers when the request is from a trusted proxy. Headers
+// from other peers are ignored. The connection address remains available
+// via PeerAddr().
+func RealIP(opts RealIPOptions) func(next http.Handler) http.Handler {
+	isTrusted := func(ip netip.Addr) bool {
+		for _, p := range opts.TrustedProxies {
+			if p.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+			peer := PeerAddr(req)
+			if _, found := req.Context().Value(peerAddrKey).(net.Addr); !found {
+				req = req.WithContext(WithPeerAddr(req.Context(), peer))
+			}
+
+			if isUnixAddr(peer) || isTrusted(addrIP(peer)) {
+				if ip := forwardedIP(req.Header, isTrusted); ip != "" {
+					req.RemoteAddr = ip
+				}
+			}
+			next.ServeHTTP(wr, req)
+		})
+	}
+}
+
+// WithPeerAddr returns a new context with the address of the connection
+// peer set. GracefulServe sets it for all the requests.
+func WithPeerAddr(ctx context.Context, addr net.Addr) context.Context {
+	return context.WithValue(ctx, peerAddrKey, addr)
+}
+
+// PeerAddr returns the address of the connection peer of the request.
+// Unlike the RemoteAddr, this is not modified by the RealIP middleware
+// and should be used for access control decisions.
+func PeerAddr(req *http.Request) net.Addr {
+	if addr, ok := req.Context().Value(peerAddrKey).(net.Addr); ok && addr != nil {
+		return addr
+	}
+	return tcpAddr(req.RemoteAddr)
+}
+
+// IsLocalPeer returns true if the request is made directly from the
+// loopback interface, i.e., the connection peer is a loopback address
+// and the request has no forwarding headers ('Forwarded',
+// 'X-Forwarded-For' or 'X-Real-IP'). Requests over Unix sockets and the
+// requests forwarded by a reverse proxy on the same host are not local
+// since they may originate from any client.
+func IsLocalPeer(req *http.Request) bool {
+	for _, h := range []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"} {
+		if len(req.Header.Values(h)) > 0 {
+			return false
+		}
+	}
+	return addrIP(PeerAddr(req)).IsLoopback()
+}
+
+// addrIP returns the IP of the address or the zero value if the address
+// is not an IP address.
+func addrIP(addr net.Addr) netip.Addr {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	ip, _ := netip.ParseAddr(host)
+	return ip.Unmap()
+}
+
+// forwardedIP returns the right-most untrusted address in X-Forwarded-For
+// (the left-most if all are trusted) or the X-Real-IP value.
+func forwardedIP(h http.Header, isTrusted func(netip.Addr) bool) string {
+	var hops []string
+	for _, v := range h.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+
+	var res string
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		res = ip.String()
+		if !isTrusted(ip.Unmap()) {
+			return res
+		}
+	}
+	if res != "" {
+		return res
+	}
+
+	if ip, err := netip.ParseAddr(strings.TrimSpace(h.Get("X-Real-IP"))); err == nil {
+		return ip.String()
+	}
+	return ""
+}
+
+func isUnixAddr(addr net.Addr) bool {
+	switch addr.Network() {
+	case "unix", "unixpacket":
+		return true
+	}
+	return false
+}
+
+// tcpAddr is the net.Addr for the RemoteAddr of requests without peer
+// address in the context (e.g., in tests).
+type tcpAddr string
+
+func (a tcpAddr) Network() string { return "tcp" }
+func (a tcpAddr) String() string  { return string(a) }
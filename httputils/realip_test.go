@@ -0,0 +1,95 @@
+package httputils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spy16/moonshot/httputils"
+)
+
+func TestRealIP(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		title      string
+		remoteAddr string
+		headers    map[string]string
+		wantIP     string
+		wantLocal  bool
+	}{
+		{
+			title:      "UntrustedPeerSpoofing",
+			remoteAddr: "203.0.113.9:4242",
+			headers:    map[string]string{"X-Real-IP": "127.0.0.1", "X-Forwarded-For": "127.0.0.1"},
+			wantIP:     "203.0.113.9:4242",
+		},
+		{
+			title:      "TrustedProxyRealIP",
+			remoteAddr: "10.0.0.2:4242",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.7"},
+			wantIP:     "198.51.100.7",
+		},
+		{
+			title:      "TrustedProxyForwardedFor",
+			remoteAddr: "10.0.0.2:4242",
+			headers:    map[string]string{"X-Forwarded-For": "127.0.0.1, 198.51.100.7, 10.0.0.3"},
+			wantIP:     "198.51.100.7",
+		},
+		{
+			title:      "LoopbackDirect",
+			remoteAddr: "127.0.0.1:4242",
+			wantIP:     "127.0.0.1:4242",
+			wantLocal:  true,
+		},
+		{
+			title:      "LoopbackPeerNotTrusted",
+			remoteAddr: "127.0.0.1:4242",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.7"},
+			wantIP:     "127.0.0.1:4242",
+			wantLocal:  false,
+		},
+		{
+			title:      "LoopbackProxyForwarded",
+			remoteAddr: "127.0.0.1:4242",
+			headers:    map[string]string{"Forwarded": "for=198.51.100.7"},
+			wantIP:     "127.0.0.1:4242",
+			wantLocal:  false,
+		},
+		{
+			title:      "SpoofedLoopbackViaProxy",
+			remoteAddr: "10.0.0.2:4242",
+			headers:    map[string]string{"X-Real-IP": "127.0.0.1"},
+			wantIP:     "127.0.0.1",
+			wantLocal:  false,
+		},
+	}
+
+	mw := httputils.RealIP(httputils.RealIPOptions{
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	})
+
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.title, func(t *testing.T) {
+			var gotIP string
+			var gotLocal bool
+			h := mw(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+				gotIP, gotLocal = req.RemoteAddr, httputils.IsLocalPeer(req)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.wantIP, gotIP)
+			assert.Equal(t, tt.wantLocal, gotLocal)
+		})
+	}
+}
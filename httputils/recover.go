@@ -0,0 +1,89 @@
+package httputils
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/go-chi/chi/middleware"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/log"
+)
+
+// PanicReporter is invoked with the recovered value and the stack trace
+// when a handler panics. Use this to report panics to external trackers.
+// Request ID (see RequestIDFrom) can be used as the reference.
+type PanicReporter func(ctx context.Context, recovered interface{}, stack []byte)
+
+// RecoverOptions controls the behaviour of the recover middleware.
+type RecoverOptions struct {
+	Reporter PanicReporter
+}
+
+// Recover is a middleware that recovers from panics in the handlers. See
+// RecoverWith() for details.
+func Recover(next http.Handler) http.Handler {
+	return RecoverWith(RecoverOptions{})(next)
+}
+
+// RecoverWith returns a middleware that recovers from panics in the
+// handlers, logs the panic along with the stack trace and responds with
+// ErrInternal. The request ID is used as the reference ID in the response
+// and logs (one is generated if the RequestID middleware is not used).
+func RecoverWith(opts RecoverOptions) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+			ww := middleware.NewWrapResponseWriter(wr, req.ProtoMajor)
+
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					// ErrAbortHandler is used to abort the response and must
+					// be propagated to the server.
+					panic(rec)
+				}
+
+				ctx := req.Context()
+				if RequestIDFrom(ctx) == "" {
+					ctx = WithRequestID(ctx, randomHex(16))
+					req = req.WithContext(ctx)
+				}
+
+				stack := debug.Stack()
+				if sp, ok := rec.(stackPanic); ok {
+					rec, stack = sp.value, sp.stack
+				}
+
+				log.Error(ctx, "recovered from panic", "panic", rec, "stack", string(stack))
+				ReportPanic(ctx, opts.Reporter, rec, stack)
+
+				if ww.Status() != 0 {
+					// response is already (partially) written. nothing we can
+					// do other than logging.
+					return
+				}
+				Respond(ww, req, http.StatusInternalServerError, errors.ErrInternal)
+			}()
+
+			next.ServeHTTP(ww, req)
+		})
+	}
+}
+
+// ReportPanic invokes the reporter (if not nil) with the recovered value
+// and the stack. Panics in the reporter are logged and not propagated.
+func ReportPanic(ctx context.Context, reporter PanicReporter, rec interface{}, stack []byte) {
+	if reporter == nil {
+		return
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			log.Error(ctx, "panic reporter panicked", "panic", v)
+		}
+	}()
+	reporter(ctx, rec, stack)
+}
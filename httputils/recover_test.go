@@ -0,0 +1,101 @@
+package httputils_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/httputils"
+	"github.com/spy16/moonshot/log"
+	"github.com/spy16/moonshot/log/logtest"
+)
+
+func TestRecoverWith(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		title      string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantBody   bool
+	}{
+		{
+			title:      "NoPanic",
+			handler:    func(wr http.ResponseWriter, req *http.Request) { wr.WriteHeader(http.StatusAccepted) },
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			title:      "PanicBeforeWrite",
+			handler:    func(wr http.ResponseWriter, req *http.Request) { panic("boom") },
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   true,
+		},
+		{
+			title: "PanicAfterWrite",
+			handler: func(wr http.ResponseWriter, req *http.Request) {
+				wr.WriteHeader(http.StatusOK)
+				panic("boom")
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.title, func(t *testing.T) {
+			ctx, rec := logtest.Capture(t)
+
+			var reported interface{}
+			var reportedRef string
+			mw := httputils.RecoverWith(httputils.RecoverOptions{
+				Reporter: func(ctx context.Context, v interface{}, stack []byte) {
+					reported = v
+					reportedRef = httputils.RequestIDFrom(ctx)
+				},
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+			rr := httptest.NewRecorder()
+			mw(tt.handler).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantStatus == http.StatusAccepted {
+				assert.Nil(t, reported)
+				assert.Empty(t, rec.Entries())
+				return
+			}
+
+			assert.Equal(t, "boom", reported)
+			assert.NotEmpty(t, reportedRef)
+
+			errLogs := rec.Filter(log.ErrorLevel)
+			require.Len(t, errLogs, 1)
+			assert.Equal(t, "boom", errLogs[0].Fields["panic"])
+			assert.Equal(t, reportedRef, errLogs[0].Fields["request_id"])
+			assert.Contains(t, errLogs[0].Fields["stack"], "recover_test.go")
+
+			if tt.wantBody {
+				var body map[string]interface{}
+				require.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+				assert.Equal(t, "internal_error", body["code"])
+				assert.Equal(t, reportedRef, body["request_id"])
+			}
+		})
+	}
+}
+
+func TestRecover_AbortHandler(t *testing.T) {
+	t.Parallel()
+
+	h := httputils.Recover(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
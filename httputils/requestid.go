@@ -0,0 +1,197 @@
+package httputils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/spy16/moonshot/log"
+)
+
+// Headers used for request correlation.
+const (
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTraceParent = "traceparent"
+	HeaderTraceState  = "tracestate"
+)
+
+const maxRequestIDLen = 128
+
+type ctxKey string
+
+var (
+	requestIDKey = ctxKey("request_id")
+	traceKey     = ctxKey("trace")
+)
+
+// TraceContext represents the W3C trace context of a request. SpanID is
+// the identifier of the span created by this service for the request.
+// State is the vendor-specific 'tracestate' received with the trace and
+// is propagated unchanged.
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+	Flags   string
+	State   string
+}
+
+// String returns the trace context in 'traceparent' header format.
+func (tc TraceContext) String() string {
+	return fmt.Sprintf("00-%s-%s-%s", tc.TraceID, tc.SpanID, tc.Flags)
+}
+
+// RequestID is a middleware that reads the request ID from the incoming
+// 'X-Request-ID' header (or generates one) and the trace context from the
+// 'traceparent' and 'tracestate' headers (or starts a new trace). Both
+// are stored in the request context, added to the log fields and the
+// request ID is echoed in the response header.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		ctx := Correlate(req.Context(),
+			req.Header.Get(HeaderRequestID),
+			req.Header.Get(HeaderTraceParent),
+			req.Header.Values(HeaderTraceState)...)
+
+		wr.Header().Set(HeaderRequestID, RequestIDFrom(ctx))
+		next.ServeHTTP(wr, req.WithContext(ctx))
+	})
+}
+
+// Correlate returns a new context with the request ID and the trace
+// context set from the incoming values, same as the RequestID middleware.
+// Invalid or empty request ID is replaced with a generated one and a new
+// trace is started if traceParent is invalid. Use this for transports
+// other than HTTP (e.g., gRPC metadata).
+func Correlate(ctx context.Context, reqID, traceParent string, traceState ...string) context.Context {
+	reqID = sanitiseRequestID(reqID)
+	if reqID == "" {
+		reqID = randomHex(16)
+	}
+
+	tc, ok := parseTraceParent(traceParent)
+	if ok {
+		tc.State = strings.Join(traceState, ",")
+	} else {
+		tc = TraceContext{TraceID: randomHex(16), Flags: "00"}
+	}
+	tc.SpanID = randomHex(8)
+
+	ctx = WithRequestID(ctx, reqID)
+	return WithTraceContext(ctx, tc)
+}
+
+// WithRequestID returns a new context with the request ID set. The ID is
+// also added to the log fields as 'request_id'.
+func WithRequestID(ctx context.Context, reqID string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey, reqID)
+	return log.InjectFields(ctx, log.Fields{"request_id": reqID})
+}
+
+// RequestIDFrom returns the request ID stored in the context, if any.
+func RequestIDFrom(ctx context.Context) string {
+	reqID, _ := ctx.Value(requestIDKey).(string)
+	return reqID
+}
+
+// WithTraceContext returns a new context with the trace context set. The
+// trace ID is also added to the log fields as 'trace_id'.
+func WithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	ctx = context.WithValue(ctx, traceKey, tc)
+	return log.InjectFields(ctx, log.Fields{"trace_id": tc.TraceID})
+}
+
+// TraceContextFrom returns the trace context stored in the context.
+func TraceContextFrom(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceKey).(TraceContext)
+	return tc, ok
+}
+
+// NewTransport returns an http.RoundTripper that propagates the request
+// ID and trace context from the outgoing request's context to downstream
+// services. If base is nil, http.DefaultTransport is used.
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &correlatingTransport{base: base}
+}
+
+type correlatingTransport struct {
+	base http.RoundTripper
+}
+
+func (ct *correlatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqID := RequestIDFrom(req.Context())
+	tc, hasTrace := TraceContextFrom(req.Context())
+	if reqID == "" && !hasTrace {
+		return ct.base.RoundTrip(req)
+	}
+
+	// RoundTripper must not modify the original request.
+	req = req.Clone(req.Context())
+	if reqID != "" && req.Header.Get(HeaderRequestID) == "" {
+		req.Header.Set(HeaderRequestID, reqID)
+	}
+	if hasTrace && req.Header.Get(HeaderTraceParent) == "" {
+		req.Header.Set(HeaderTraceParent, tc.String())
+		req.Header.Del(HeaderTraceState)
+		if tc.State != "" {
+			req.Header.Set(HeaderTraceState, tc.State)
+		}
+	}
+	return ct.base.RoundTrip(req)
+}
+
+func parseTraceParent(s string) (TraceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || parts[0] == "ff" || !isHex(parts[0], 2) {
+		return TraceContext{}, false
+	}
+	// later versions may append fields but version 00 has exactly four.
+	if parts[0] == "00" && len(parts) != 4 {
+		return TraceContext{}, false
+	}
+
+	tc := TraceContext{TraceID: parts[1], SpanID: parts[2], Flags: parts[3]}
+	if !isHex(tc.TraceID, 32) || !isHex(tc.SpanID, 16) || !isHex(tc.Flags, 2) ||
+		isZeros(tc.TraceID) || isZeros(tc.SpanID) {
+		return TraceContext{}, false
+	}
+	return tc, true
+}
+
+func sanitiseRequestID(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > maxRequestIDLen {
+		return ""
+	}
+	for _, r := range s {
+		if r < 0x21 || r > 0x7e {
+			return ""
+		}
+	}
+	return s
+}
+
+func isHex(s string, size int) bool {
+	if len(s) != size {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= '0' && r <= '9') && !(r >= 'a' && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZeros(s string) bool { return strings.Trim(s, "0") == "" }
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,141 @@
+package httputils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/httputils"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		title       string
+		reqID       string
+		traceParent string
+		traceState  string
+		wantReqID   string
+		wantTraceID string
+		wantState   string
+	}{
+		{
+			title: "Generated",
+		},
+		{
+			title:     "FromHeader",
+			reqID:     "abc-123",
+			wantReqID: "abc-123",
+		},
+		{
+			title: "InvalidHeader",
+			reqID: "abc 123",
+		},
+		{
+			title:       "WithTraceParent",
+			traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			title:       "WithTraceState",
+			traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			traceState:  "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7",
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantState:   "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7",
+		},
+		{
+			title:       "FutureVersionExtraFields",
+			traceParent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what-the-future",
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			title:       "InvalidTraceParent",
+			traceParent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		},
+		{
+			title:       "Version00ExtraFields",
+			traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			traceState:  "congo=t61rcWkgMzE",
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.title, func(t *testing.T) {
+			var gotReqID string
+			var gotTrace httputils.TraceContext
+			h := httputils.RequestID(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+				gotReqID = httputils.RequestIDFrom(req.Context())
+				gotTrace, _ = httputils.TraceContextFrom(req.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.reqID != "" {
+				req.Header.Set(httputils.HeaderRequestID, tt.reqID)
+			}
+			if tt.traceParent != "" {
+				req.Header.Set(httputils.HeaderTraceParent, tt.traceParent)
+			}
+			if tt.traceState != "" {
+				req.Header.Set(httputils.HeaderTraceState, tt.traceState)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.NotEmpty(t, gotReqID)
+			assert.Equal(t, gotReqID, rec.Header().Get(httputils.HeaderRequestID))
+			if tt.wantReqID != "" {
+				assert.Equal(t, tt.wantReqID, gotReqID)
+			}
+
+			assert.Len(t, gotTrace.TraceID, 32)
+			assert.Len(t, gotTrace.SpanID, 16)
+			if tt.wantTraceID != "" {
+				assert.Equal(t, tt.wantTraceID, gotTrace.TraceID)
+			} else {
+				assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", gotTrace.TraceID)
+			}
+			assert.Equal(t, tt.wantState, gotTrace.State)
+		})
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	t.Parallel()
+
+	var gotReqID, gotTraceParent, gotTraceState string
+	downstream := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		gotReqID = req.Header.Get(httputils.HeaderRequestID)
+		gotTraceParent = req.Header.Get(httputils.HeaderTraceParent)
+		gotTraceState = req.Header.Get(httputils.HeaderTraceState)
+	}))
+	defer downstream.Close()
+
+	client := &http.Client{Transport: httputils.NewTransport(nil)}
+	h := httputils.RequestID(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		outReq, _ := http.NewRequestWithContext(req.Context(), http.MethodGet, downstream.URL, nil)
+		resp, err := client.Do(outReq)
+		if assert.NoError(t, err) {
+			_ = resp.Body.Close()
+		}
+		httputils.Respond(wr, req, 0, errors.ErrNotFound)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(httputils.HeaderRequestID, "req-1")
+	req.Header.Set(httputils.HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(httputils.HeaderTraceState, "congo=t61rcWkgMzE")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, "req-1", gotReqID)
+	assert.True(t, strings.HasPrefix(gotTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-"))
+	assert.NotContains(t, gotTraceParent, "00f067aa0ba902b7")
+	assert.Equal(t, "congo=t61rcWkgMzE", gotTraceState)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"request_id":"req-1"`)
+}
@@ -1,68 +1,327 @@
 package httputils
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/tls"
+	"encoding/xml"
+	stderrors "errors"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
 	"github.com/spy16/moonshot/errors"
 	"github.com/spy16/moonshot/log"
 )
 
-// Respond writes an HTTP response to the client.
+// Respond writes an HTTP response to the client. If v is an error, status
+// is derived from the error using ErrorStatus(). Response format is chosen
+// based on the 'Accept' header from the registered encoders (JSON, msgpack,
+// CBOR, XML, YAML and text by default, see RegisterEncoder). Values that
+// cannot be encoded in the chosen format are written as JSON.
+//
+// Large slices are written while being encoded. If the encoding fails
+// after a part of the response is written, the status cannot be changed
+// anymore. So the response is aborted (by panicking with
+// http.ErrAbortHandler) so that the clients do not mistake the truncated
+// response for a complete one.
 func Respond(wr http.ResponseWriter, req *http.Request, status int, v interface{}) {
 	if err, isErr := v.(error); isErr {
-		switch {
-		case errors.Is(err, errors.ErrInvalid):
-			status = http.StatusBadRequest
+		status = ErrorStatus(err)
 
-		case errors.Is(err, errors.ErrNotFound):
-			status = http.StatusNotFound
+		e := errors.E(fromStdErr(err))
+		if d := e.RetryAfter(); d > 0 {
+			wr.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(d)))
+		}
+		v = errorBody{
+			Error:     e,
+			Fields:    e.Fields(),
+			RequestID: RequestIDFrom(req.Context()),
+		}
+	}
 
-		case errors.Is(err, errors.ErrConflict):
-			status = http.StatusConflict
+	entry := negotiate(req)
+	wr.Header().Add("Vary", "Accept")
 
-		case errors.Is(err, errors.ErrUnsupported):
-			status = http.StatusUnprocessableEntity
+	if shouldStream(v) {
+		sw := &streamWriter{wr: wr, status: status, contentType: entry.contentType}
+		err := entry.enc(sw, req, v)
+		switch {
+		case err == nil:
+			sw.start()
+			return
 
-		default:
-			status = http.StatusInternalServerError
+		case sw.started:
+			log.Errorf(req.Context(), "failed to write '%s' response, aborting: %v", entry.mediaType, err)
+			panic(http.ErrAbortHandler)
 		}
+		// nothing is written yet, the buffered path below handles it.
+	}
 
-		v = errors.E(err)
+	var buf bytes.Buffer
+	if err := entry.enc(&buf, req, v); err != nil {
+		log.Warnf(req.Context(), "failed to encode response as '%s', falling back to json: %v", entry.mediaType, err)
+		buf.Reset()
+		entry = encoderEntry{contentType: "application/json; charset=utf-8"}
+		if err := EncodeJSON(&buf, req, v); err != nil {
+			log.Errorf(req.Context(), "failed to encode response as json: %v", err)
+			buf.Reset()
+			status = http.StatusInternalServerError
+			_ = EncodeJSON(&buf, req, errorBody{Error: errors.ErrInternal, RequestID: RequestIDFrom(req.Context())})
+		}
 	}
 
-	wr.Header().Set("Content-Type", "application/json; charset=utf-8")
+	wr.Header().Set("Content-Type", entry.contentType)
 	wr.WriteHeader(status)
-	_ = json.NewEncoder(wr).Encode(v)
+	_, _ = wr.Write(buf.Bytes())
+}
+
+// streamWriter writes the header on the first write so that the encoding
+// errors before any output can still be responded with a proper status.
+type streamWriter struct {
+	wr          http.ResponseWriter
+	status      int
+	contentType string
+	started     bool
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	sw.start()
+	return sw.wr.Write(p)
+}
+
+func (sw *streamWriter) start() {
+	if sw.started {
+		return
+	}
+	sw.started = true
+	sw.wr.Header().Set("Content-Type", sw.contentType)
+	sw.wr.WriteHeader(sw.status)
+}
+
+// ErrorStatus returns the HTTP status code for the error based on its
+// category. Unknown errors are mapped to 500.
+func ErrorStatus(err error) int {
+	err = fromStdErr(err)
+	switch {
+	case errors.Is(err, errors.ErrInvalid):
+		return http.StatusBadRequest
+
+	case errors.Is(err, errors.ErrNotFound):
+		return http.StatusNotFound
+
+	case errors.Is(err, errors.ErrConflict):
+		return http.StatusConflict
+
+	case errors.Is(err, errors.ErrForbidden):
+		return http.StatusForbidden
+
+	case errors.Is(err, errors.ErrUnsupported):
+		return http.StatusUnprocessableEntity
+
+	case errors.Is(err, errors.ErrUnavailable):
+		return http.StatusServiceUnavailable
+
+	case errors.Is(err, errors.ErrTimeout):
+		return http.StatusGatewayTimeout
+
+	case errors.Is(err, errors.ErrRateLimited):
+		return http.StatusTooManyRequests
+
+	case errors.Is(err, errors.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// fromStdErr converts the well-known errors returned by the standard
+// library (e.g., body read errors due to BodyLimit) to Error.
+func fromStdErr(err error) error {
+	var e errors.Error
+	if stderrors.As(err, &e) {
+		return err
+	}
+
+	var mbe *http.MaxBytesError
+	switch {
+	case stderrors.As(err, &mbe):
+		return errors.ErrTooLarge.WithMsgf("request body must not exceed %d bytes", mbe.Limit)
+
+	case stderrors.Is(err, context.DeadlineExceeded):
+		return errors.ErrTimeout.WithCausef("%v", err)
+	}
+	return err
+}
+
+// errorBody is the representation of errors written by Respond.
+type errorBody struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	errors.Error
+	Fields    []errors.FieldError `json:"fields,omitempty" xml:"field,omitempty"`
+	RequestID string              `json:"request_id,omitempty" xml:"request_id,omitempty"`
+}
+
+// String is used for the 'text/plain' responses.
+func (eb errorBody) String() string {
+	var sb strings.Builder
+	sb.WriteString(eb.Code + ": " + eb.Error.Error())
+	for _, fe := range eb.Fields {
+		sb.WriteString("\n  " + fe.Field + ": " + fe.Reason)
+	}
+	if eb.RequestID != "" {
+		sb.WriteString("\nrequest_id: " + eb.RequestID)
+	}
+	return sb.String()
+}
+
+// ServeOption can be passed to GracefulServe() to customise the server.
+type ServeOption func(srv *http.Server)
+
+// Timeouts holds the server level timeouts. Values are set as is on the
+// corresponding http.Server fields (i.e., negative disables the timeout).
+type Timeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
 }
 
-// GracefulServe starts HTTP server on addr. Server shuts down gracefully when
-// context is cancelled.
-func GracefulServe(ctx context.Context, gracePeriod time.Duration, addr string, h http.Handler) error {
+// DefaultTimeouts is used by GracefulServe unless WithTimeouts is passed.
+var DefaultTimeouts = Timeouts{
+	ReadHeader: 10 * time.Second,
+	Read:       60 * time.Second,
+	Write:      60 * time.Second,
+	Idle:       120 * time.Second,
+}
+
+// WithTimeouts sets the read-header, read, write and idle timeouts of the
+// server. Use the Timeout middleware for handler level deadlines.
+func WithTimeouts(t Timeouts) ServeOption {
+	return func(srv *http.Server) {
+		srv.ReadHeaderTimeout = t.ReadHeader
+		srv.ReadTimeout = t.Read
+		srv.WriteTimeout = t.Write
+		srv.IdleTimeout = t.Idle
+	}
+}
+
+type shutdownKey struct{}
+
+// ShutdownSignal returns a channel that is closed when the server started
+// by GracefulServe begins shutting down. Long-lived handlers (e.g., streams)
+// should return when it is closed since shutdown waits for all the active
+// requests. Returns nil channel for requests not served by GracefulServe.
+func ShutdownSignal(ctx context.Context) <-chan struct{} {
+	ch, _ := ctx.Value(shutdownKey{}).(chan struct{})
+	return ch
+}
+
+// WithH2C enables HTTP/2 without TLS (h2c). This is required for serving
+// gRPC on the same port as HTTP without TLS.
+func WithH2C() ServeOption {
+	return func(srv *http.Server) {
+		h2s := &http2.Server{IdleTimeout: srv.IdleTimeout}
+		// registers the graceful shutdown (GOAWAY) of HTTP/2 connections.
+		_ = http2.ConfigureServer(srv, h2s)
+		srv.Handler = h2c.NewHandler(srv.Handler, h2s)
+	}
+}
+
+// GracefulServe starts HTTP server on the listener (see Listen). Server shuts
+// down gracefully when context is cancelled and GracefulServe returns after
+// the shutdown completes (or the grace period ends). DefaultTimeouts are
+// applied unless overridden using WithTimeouts. Use WithTLS to serve HTTPS.
+func GracefulServe(ctx context.Context, gracePeriod time.Duration, lis net.Listener, h http.Handler, opts ...ServeOption) error {
+	shutdown := make(chan struct{})
 	srv := &http.Server{
-		Addr:    addr,
+		Addr:    lis.Addr().String(),
 		Handler: h,
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), shutdownKey{}, shutdown)
+		},
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			addr := c.RemoteAddr()
+			if addr == nil {
+				// unnamed unix socket peers.
+				addr = &net.UnixAddr{Net: c.LocalAddr().Network()}
+			}
+			return WithPeerAddr(ctx, addr)
+		},
+	}
+	srv.RegisterOnShutdown(func() { close(shutdown) })
+	WithTimeouts(DefaultTimeouts)(srv)
+	for _, opt := range opts {
+		opt(srv)
 	}
 
+	// Shutdown does not wait for the hijacked connections (e.g., h2c and
+	// websockets). So the active handlers are tracked separately.
+	var active int64
+	next := srv.Handler
+	srv.Handler = http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&active, 1)
+		defer atomic.AddInt64(&active, -1)
+		next.ServeHTTP(wr, req)
+	})
+
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		<-ctx.Done()
 
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), gracePeriod)
 		defer cancel()
 
 		log.Warnf(ctx, "server shutting down (reason: context_cancelled)")
-		if err := srv.Shutdown(shutdownCtx); err != nil {
+		err := srv.Shutdown(shutdownCtx)
+		if err == nil {
+			err = waitIdle(shutdownCtx, &active)
+		}
+		if err != nil {
 			log.Errorf(ctx, "graceful shutdown failed: %v", err)
 			return
 		}
 		log.Infof(ctx, "graceful shutdown complete")
 	}()
 
-	err := srv.ListenAndServe()
+	var err error
+	if hasCertificate(srv.TLSConfig) {
+		err = srv.ServeTLS(lis, "", "")
+	} else {
+		err = srv.Serve(lis)
+	}
 	if err != nil && err != http.ErrServerClosed {
 		return err
 	}
+	<-done
+	return nil
+}
+
+// hasCertificate returns true if TLS is enabled using WithTLS. TLSConfig
+// alone is not sufficient since WithH2C creates one.
+func hasCertificate(cfg *tls.Config) bool {
+	return cfg != nil && (len(cfg.Certificates) > 0 || cfg.GetCertificate != nil || cfg.GetConfigForClient != nil)
+}
+
+func waitIdle(ctx context.Context, active *int64) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for atomic.LoadInt64(active) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-ticker.C:
+		}
+	}
 	return nil
 }
@@ -0,0 +1,83 @@
+package httputils_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/httputils"
+)
+
+func TestRespond_Errors(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		title          string
+		err            error
+		wantStatus     int
+		wantCode       string
+		wantRetryAfter string
+	}{
+		{
+			title:      "Error",
+			err:        errors.ErrNotFound,
+			wantStatus: http.StatusNotFound,
+			wantCode:   "not_found",
+		},
+		{
+			title:      "Forbidden",
+			err:        errors.ErrForbidden,
+			wantStatus: http.StatusForbidden,
+			wantCode:   "forbidden",
+		},
+		{
+			title:          "WrappedWithRetryAfter",
+			err:            fmt.Errorf("calling upstream: %w", errors.ErrRateLimited.WithRetryAfter(1500*time.Millisecond)),
+			wantStatus:     http.StatusTooManyRequests,
+			wantCode:       "rate_limited",
+			wantRetryAfter: "2",
+		},
+		{
+			title:      "WrappedInternal",
+			err:        fmt.Errorf("query failed: %w", errors.ErrInternal.WithCausef("db down")),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   "internal_error",
+		},
+		{
+			title:      "Deadline",
+			err:        fmt.Errorf("query failed: %w", context.DeadlineExceeded),
+			wantStatus: http.StatusGatewayTimeout,
+			wantCode:   "timeout",
+		},
+		{
+			title:      "Unknown",
+			err:        fmt.Errorf("boom"),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   "internal_error",
+		},
+	}
+
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.title, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			httputils.Respond(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, tt.err)
+
+			var body struct {
+				Code string `json:"code"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantCode, body.Code)
+			assert.Equal(t, tt.wantRetryAfter, rec.Header().Get("Retry-After"))
+		})
+	}
+}
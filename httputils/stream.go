@@ -0,0 +1,311 @@
+package httputils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spy16/moonshot/errors"
+)
+
+// DefaultHeartbeat is the default interval of idle stream heartbeats.
+const DefaultHeartbeat = 15 * time.Second
+
+// ErrStreamClosed is returned by Err() and Send() after the stream is closed
+// using Close().
+var ErrStreamClosed = stderrors.New("stream closed")
+
+// StreamOption can be passed to SSE() and NDJSON() to customise the stream.
+type StreamOption func(opts *streamOptions)
+
+type streamOptions struct {
+	heartbeat time.Duration
+}
+
+// WithHeartbeat sets the interval after which a heartbeat is written to an
+// idle stream to keep the connection alive through proxies. Zero or
+// negative disables heartbeats. Defaults to DefaultHeartbeat.
+func WithHeartbeat(d time.Duration) StreamOption {
+	return func(opts *streamOptions) { opts.heartbeat = d }
+}
+
+// Event is a Server-Sent Event. Data is written as is if it is a string or
+// []byte and JSON encoded otherwise.
+type Event struct {
+	ID    string
+	Event string
+	Data  interface{}
+	Retry time.Duration
+}
+
+// SSEStream writes Server-Sent Events (text/event-stream) to the client.
+type SSEStream struct {
+	*stream
+	lastEventID string
+}
+
+// SSE starts a Server-Sent Events stream on the response. The returned
+// stream must be closed before the handler returns. Handlers should stop
+// when Done() is closed (i.e., client disconnected, server is shutting
+// down or a write failed).
+//
+//	stream, err := httputils.SSE(wr, req)
+//	if err != nil {
+//		httputils.Respond(wr, req, 0, err)
+//		return
+//	}
+//	defer stream.Close()
+//
+//	for {
+//		select {
+//		case <-stream.Done():
+//			return
+//		case job := <-updates:
+//			_ = stream.Send(httputils.Event{ID: job.Seq, Data: job})
+//		}
+//	}
+func SSE(wr http.ResponseWriter, req *http.Request, opts ...StreamOption) (*SSEStream, error) {
+	s, err := startStream(wr, req, "text/event-stream", []byte(": heartbeat\n\n"), opts)
+	if err != nil {
+		return nil, err
+	}
+	return &SSEStream{
+		stream:      s,
+		lastEventID: req.Header.Get("Last-Event-ID"),
+	}, nil
+}
+
+// LastEventID returns the ID of the last event received by the client
+// before reconnecting. Handlers can use it to resume the stream.
+func (s *SSEStream) LastEventID() string { return s.lastEventID }
+
+// Send writes the event and flushes it to the client.
+func (s *SSEStream) Send(ev Event) error {
+	var data []byte
+	switch v := ev.Data.(type) {
+	case string:
+		data = []byte(v)
+
+	case []byte:
+		data = v
+
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	if ev.ID != "" {
+		buf.WriteString("id: " + singleLine(ev.ID) + "\n")
+	}
+	if ev.Event != "" {
+		buf.WriteString("event: " + singleLine(ev.Event) + "\n")
+	}
+	if ev.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(bytes.TrimSuffix(line, []byte("\r")))
+		buf.WriteString("\n")
+	}
+	buf.WriteString("\n")
+
+	return s.write(buf.Bytes())
+}
+
+// NDJSONStream writes newline delimited JSON values (application/x-ndjson)
+// to the client.
+type NDJSONStream struct {
+	*stream
+}
+
+// NDJSON starts a newline delimited JSON stream on the response. Heartbeats
+// are written as empty lines. See SSE() for the usage.
+func NDJSON(wr http.ResponseWriter, req *http.Request, opts ...StreamOption) (*NDJSONStream, error) {
+	s, err := startStream(wr, req, "application/x-ndjson", []byte("\n"), opts)
+	if err != nil {
+		return nil, err
+	}
+	return &NDJSONStream{stream: s}, nil
+}
+
+// Send writes v as a JSON line and flushes it to the client.
+func (s *NDJSONStream) Send(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.write(append(data, '\n'))
+}
+
+type stream struct {
+	rc        *http.ResponseController
+	heartbeat []byte
+	interval  time.Duration
+
+	mu        sync.Mutex
+	wr        http.ResponseWriter
+	err       error
+	lastWrite time.Time
+	done      chan struct{}
+	exited    chan struct{}
+}
+
+func startStream(wr http.ResponseWriter, req *http.Request, contentType string, heartbeat []byte, opts []StreamOption) (*stream, error) {
+	o := streamOptions{heartbeat: DefaultHeartbeat}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if !canFlush(wr) {
+		return nil, errors.ErrUnsupported.WithCausef("response writer does not support flushing")
+	}
+
+	h := wr.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", contentType)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+
+	s := &stream{
+		rc:        http.NewResponseController(wr),
+		heartbeat: heartbeat,
+		interval:  o.heartbeat,
+		wr:        wr,
+		lastWrite: time.Now(),
+		done:      make(chan struct{}),
+		exited:    make(chan struct{}),
+	}
+
+	// streams outlive the server read/write timeouts.
+	_ = s.rc.SetReadDeadline(time.Time{})
+	_ = s.rc.SetWriteDeadline(time.Time{})
+
+	wr.WriteHeader(http.StatusOK)
+	if err := s.rc.Flush(); err != nil {
+		return nil, err
+	}
+
+	go s.run(req.Context(), ShutdownSignal(req.Context()))
+	return s, nil
+}
+
+// Done returns a channel that is closed when the stream ends (i.e., client
+// disconnected, server is shutting down, a write failed or Close() was
+// called).
+func (s *stream) Done() <-chan struct{} { return s.done }
+
+// Err returns the reason the stream ended: the request context error,
+// http.ErrServerClosed, the write error or ErrStreamClosed. Returns nil
+// while the stream is active.
+func (s *stream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close ends the stream. It must be called before the handler returns.
+func (s *stream) Close() error {
+	s.mu.Lock()
+	s.end(ErrStreamClosed)
+	s.mu.Unlock()
+
+	<-s.exited
+	return nil
+}
+
+func (s *stream) run(ctx context.Context, shutdown <-chan struct{}) {
+	defer close(s.exited)
+
+	var tick <-chan time.Time
+	if s.interval > 0 {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.done:
+			return
+
+		case <-ctx.Done():
+			s.mu.Lock()
+			s.end(ctx.Err())
+			s.mu.Unlock()
+			return
+
+		case <-shutdown:
+			s.mu.Lock()
+			s.end(http.ErrServerClosed)
+			s.mu.Unlock()
+			return
+
+		case now := <-tick:
+			s.mu.Lock()
+			if s.err == nil && now.Sub(s.lastWrite) >= s.interval {
+				_ = s.writeLocked(s.heartbeat)
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *stream) write(p []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeLocked(p)
+}
+
+func (s *stream) writeLocked(p []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	if _, err := s.wr.Write(p); err != nil {
+		s.end(err)
+		return err
+	}
+	if err := s.rc.Flush(); err != nil {
+		s.end(err)
+		return err
+	}
+	s.lastWrite = time.Now()
+	return nil
+}
+
+// end marks the stream as done. Must be called with mu held.
+func (s *stream) end(err error) {
+	if s.err == nil {
+		s.err = err
+		close(s.done)
+	}
+}
+
+// canFlush returns true if wr or any of the writers it wraps supports
+// flushing.
+func canFlush(wr http.ResponseWriter) bool {
+	for {
+		if _, ok := wr.(http.Flusher); ok {
+			return true
+		}
+		u, ok := wr.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		wr = u.Unwrap()
+	}
+}
+
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
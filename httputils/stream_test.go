@@ -0,0 +1,160 @@
+package httputils_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/httputils"
+)
+
+func TestSSE(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		stream, err := httputils.SSE(wr, req, httputils.WithHeartbeat(-1))
+		require.NoError(t, err)
+		defer stream.Close()
+
+		_ = stream.Send(httputils.Event{ID: "1", Event: "progress", Data: map[string]int{"done": 10}})
+		_ = stream.Send(httputils.Event{ID: stream.LastEventID() + "\n2", Data: "line1\nline2", Retry: 3 * time.Second})
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Last-Event-ID", "41")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+	assert.Equal(t, []string{
+		"id: 1", "event: progress", `data: {"done":10}`, "",
+		"id: 412", "retry: 3000", "data: line1", "data: line2", "",
+	}, readLines(t, resp))
+}
+
+func TestNDJSON(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		stream, err := httputils.NDJSON(wr, req, httputils.WithHeartbeat(20*time.Millisecond))
+		require.NoError(t, err)
+		defer stream.Close()
+
+		_ = stream.Send(map[string]int{"n": 1})
+		time.Sleep(50 * time.Millisecond)
+		_ = stream.Send(map[string]int{"n": 2})
+
+		require.NoError(t, stream.Close())
+		assert.ErrorIs(t, stream.Send(1), httputils.ErrStreamClosed)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+	lines := readLines(t, resp)
+	assert.Equal(t, `{"n":1}`, lines[0])
+	assert.Equal(t, `{"n":2}`, lines[len(lines)-1])
+	assert.Contains(t, lines[1:len(lines)-1], "", "heartbeat expected")
+}
+
+func TestStream_ClientDisconnect(t *testing.T) {
+	t.Parallel()
+
+	gotErr := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		stream, err := httputils.SSE(wr, req)
+		require.NoError(t, err)
+		defer stream.Close()
+
+		<-stream.Done()
+		gotErr <- stream.Err()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	cancel()
+
+	select {
+	case err := <-gotErr:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream was not closed on client disconnect")
+	}
+}
+
+func TestStream_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+
+	gotErr := make(chan error, 1)
+	h := http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		stream, err := httputils.SSE(wr, req)
+		require.NoError(t, err)
+		defer stream.Close()
+
+		<-stream.Done()
+		gotErr <- stream.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- httputils.GracefulServe(ctx, 10*time.Second, lis, h) }()
+
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		resp, err = http.Get("http://" + addr)
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+	defer resp.Body.Close()
+
+	start := time.Now()
+	cancel()
+	assert.ErrorIs(t, <-gotErr, http.ErrServerClosed)
+	assert.NoError(t, <-served)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestStream_Unsupported(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err := httputils.SSE(nonFlusher{httptest.NewRecorder()}, req)
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+type nonFlusher struct{ http.ResponseWriter }
+
+func readLines(t *testing.T, resp *http.Response) []string {
+	t.Helper()
+
+	var lines []string
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		lines = append(lines, strings.TrimSuffix(sc.Text(), "\r"))
+	}
+	require.NoError(t, sc.Err())
+	return lines
+}
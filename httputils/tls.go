@@ -0,0 +1,271 @@
+package httputils
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spy16/moonshot/log"
+)
+
+// DefaultReloadInterval is the interval at which the certificate files are
+// checked for changes.
+const DefaultReloadInterval = 10 * time.Second
+
+// TLSOptions controls the TLS configuration created by NewTLSConfig.
+type TLSOptions struct {
+	// CertFile and KeyFile are the PEM encoded certificate (chain) and the
+	// private key. Files are reloaded when they change.
+	CertFile string
+	KeyFile  string
+
+	// SelfSigned generates a self-signed certificate for the Hosts (defaults
+	// to localhost) when CertFile and KeyFile are not set. For development
+	// use only.
+	SelfSigned bool
+	Hosts      []string
+
+	// ClientCAFiles are the PEM encoded CA bundles for verifying client
+	// certificates (mTLS). Client certificates are required unless
+	// ClientCertOptional is set.
+	ClientCAFiles      []string
+	ClientCertOptional bool
+
+	// ReloadInterval is the interval at which the certificate files are
+	// checked for changes. Defaults to DefaultReloadInterval. Negative
+	// value disables reloading.
+	ReloadInterval time.Duration
+}
+
+// NewTLSConfig returns the TLS configuration for serving. Certificates are
+// reloaded on change until the context is cancelled and the last valid
+// certificate is used if the reload fails.
+func NewTLSConfig(ctx context.Context, opts TLSOptions) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	switch {
+	case opts.CertFile != "" || opts.KeyFile != "":
+		cr, err := newCertReloader(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if opts.ReloadInterval == 0 {
+			opts.ReloadInterval = DefaultReloadInterval
+		}
+		if opts.ReloadInterval > 0 {
+			go cr.watch(ctx, opts.ReloadInterval)
+		}
+		cfg.GetCertificate = cr.getCertificate
+
+	case opts.SelfSigned:
+		cert, err := SelfSignedCert(opts.Hosts...)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+
+	default:
+		return nil, fmt.Errorf("tls: certificate and key files are required")
+	}
+
+	if len(opts.ClientCAFiles) > 0 {
+		pool := x509.NewCertPool()
+		for _, file := range opts.ClientCAFiles {
+			pem, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("tls: failed to read client CA: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("tls: no certificates found in client CA '%s'", file)
+			}
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		if opts.ClientCertOptional {
+			cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	return cfg, nil
+}
+
+// WithTLS enables TLS using the given configuration.
+func WithTLS(cfg *tls.Config) ServeOption {
+	return func(srv *http.Server) {
+		cfg = cfg.Clone()
+		if srv.TLSConfig != nil {
+			// retain the protocols set by WithH2C (http2.ConfigureServer).
+			cfg.NextProtos = append(cfg.NextProtos, srv.TLSConfig.NextProtos...)
+		}
+		srv.TLSConfig = cfg
+	}
+}
+
+// ClientCertIdentity sets the identity of the requests with verified client
+// certificate (mTLS) using CertIdentity().
+func ClientCertIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 && len(req.TLS.VerifiedChains[0]) > 0 {
+			id := CertIdentity(req.TLS.VerifiedChains[0][0])
+			req = req.WithContext(WithIdentity(req.Context(), id))
+		}
+		next.ServeHTTP(wr, req)
+	})
+}
+
+// CertIdentity returns the identity for the client certificate. Subject is
+// the common name (or the first URI/DNS SAN if not set). Issuer and serial
+// number are added as 'issuer' and 'serial' attributes.
+func CertIdentity(cert *x509.Certificate) Identity {
+	subject := cert.Subject.CommonName
+	if subject == "" && len(cert.URIs) > 0 {
+		subject = cert.URIs[0].String()
+	}
+	if subject == "" && len(cert.DNSNames) > 0 {
+		subject = cert.DNSNames[0]
+	}
+
+	return Identity{
+		Subject: subject,
+		Attrs: map[string]string{
+			"issuer": cert.Issuer.CommonName,
+			"serial": cert.SerialNumber.String(),
+		},
+	}
+}
+
+// SelfSignedCert generates a self-signed certificate valid for the hosts
+// (names or IPs) for 30 days. Defaults to localhost, 127.0.0.1 and ::1.
+func SelfSignedCert(hosts ...string) (tls.Certificate, error) {
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1", "::1"}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	tpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hosts[0], Organization: []string{"moonshot (self-signed)"}},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(30 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tpl.IPAddresses = append(tpl.IPAddresses, ip)
+		} else {
+			tpl.DNSNames = append(tpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: tpl}, nil
+}
+
+// certReloader reloads the certificate when the files change.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	version string
+	failed  string
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	cr := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := cr.reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+func (cr *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert, nil
+}
+
+func (cr *certReloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			reloaded, err := cr.reload()
+			if err != nil {
+				log.Errorf(ctx, "failed to reload tls certificate (using the previous one): %v", err)
+			} else if reloaded {
+				log.Infof(ctx, "tls certificate reloaded from '%s'", cr.certFile)
+			}
+		}
+	}
+}
+
+// reload loads the certificate if the files have changed since the last
+// load and returns true if it was reloaded.
+func (cr *certReloader) reload() (bool, error) {
+	version, err := fileVersion(cr.certFile, cr.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("tls: %w", err)
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	// failed versions are not retried to avoid repeated errors until the
+	// files change again.
+	if version == cr.version || version == cr.failed {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		cr.failed = version
+		return false, fmt.Errorf("tls: %w", err)
+	}
+	cr.cert, cr.version = &cert, version
+	return true, nil
+}
+
+// fileVersion returns a string that changes when any of the files is
+// modified or replaced.
+func fileVersion(files ...string) (string, error) {
+	var version string
+	for _, file := range files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		version += fmt.Sprintf("%d:%d;", fi.ModTime().UnixNano(), fi.Size())
+	}
+	return version, nil
+}
@@ -0,0 +1,205 @@
+package httputils_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/httputils"
+)
+
+func TestNewTLSConfig_Reload(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, mustSelfSigned(t, "a.example"), certFile, keyFile)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg, err := httputils.NewTLSConfig(ctx, httputils.TLSOptions{
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		ReloadInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "a.example", servedName(t, cfg))
+
+	// invalid files must not replace the current certificate.
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0o600))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "a.example", servedName(t, cfg))
+
+	writeCert(t, mustSelfSigned(t, "bb.example"), certFile, keyFile)
+	assert.Eventually(t, func() bool {
+		return servedName(t, cfg) == "bb.example"
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestNewTLSConfig_Invalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := httputils.NewTLSConfig(context.Background(), httputils.TLSOptions{})
+	assert.Error(t, err)
+
+	_, err = httputils.NewTLSConfig(context.Background(), httputils.TLSOptions{
+		CertFile: "missing.crt",
+		KeyFile:  "missing.key",
+	})
+	assert.Error(t, err)
+}
+
+func TestGracefulServe_MutualTLS(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.crt")
+	writeCert(t, ca, caFile, filepath.Join(dir, "ca.key"))
+
+	cfg, err := httputils.NewTLSConfig(context.Background(), httputils.TLSOptions{
+		SelfSigned:    true,
+		ClientCAFiles: []string{caFile},
+	})
+	require.NoError(t, err)
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+
+	h := httputils.ClientCertIdentity(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		id, _ := httputils.IdentityFrom(req.Context())
+		_, _ = io.WriteString(wr, id.Subject)
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- httputils.GracefulServe(ctx, time.Second, lis, h, httputils.WithTLS(cfg)) }()
+	defer func() {
+		cancel()
+		assert.NoError(t, <-served)
+	}()
+
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true, Certificates: certs},
+		}}
+	}
+
+	client := newClient(signClientCert(t, ca, "svc-a"))
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		resp, err = client.Get("https://" + addr)
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "svc-a", string(body))
+
+	_, err = newClient().Get("https://" + addr)
+	assert.Error(t, err, "client certificate must be required")
+}
+
+func TestCertIdentity(t *testing.T) {
+	t.Parallel()
+
+	ca := newTestCA(t)
+	cert := signClientCert(t, ca, "svc-b")
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+
+	id := httputils.CertIdentity(leaf)
+	assert.Equal(t, "svc-b", id.Subject)
+	assert.Equal(t, "test-ca", id.Attrs["issuer"])
+	assert.Equal(t, "2", id.Attrs["serial"])
+}
+
+func mustSelfSigned(t *testing.T, hosts ...string) tls.Certificate {
+	t.Helper()
+	cert, err := httputils.SelfSignedCert(hosts...)
+	require.NoError(t, err)
+	return cert
+}
+
+func newTestCA(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func signClientCert(t *testing.T, ca tls.Certificate, cn string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca.Leaf, &key.PublicKey, ca.PrivateKey)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func writeCert(t *testing.T, cert tls.Certificate, certFile, keyFile string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+}
+
+func servedName(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+
+	cert, err := cfg.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
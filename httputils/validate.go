@@ -0,0 +1,232 @@
+package httputils
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spy16/moonshot/errors"
+)
+
+// Validator can be implemented by request types for validations that
+// cannot be expressed using the 'validate' tags. Validate is called after
+// the tag based validations pass.
+type Validator interface {
+	Validate() error
+}
+
+// Validate validates v using the 'validate' struct tags and the Validator
+// interface. Failures are returned as ErrInvalid with field details.
+// Supported rules (comma separated):
+//
+//	required   value must not be zero (or nil)
+//	min=N      minimum value for numbers, length for strings/slices/maps
+//	max=N      maximum value for numbers, length for strings/slices/maps
+//	len=N      exact length for strings/slices/maps
+//	oneof=a b  value must be one of the space separated values
+//	email      value must be a valid email address
+//
+// Except 'required', rules are not applied on nil pointers and 'oneof',
+// 'email' are not applied on empty strings.
+//
+// Nested structs, pointers to structs and slices of structs are validated
+// recursively.
+func Validate(v interface{}) error {
+	var fieldErrs []errors.FieldError
+	if err := validateValue(reflect.ValueOf(v), "", &fieldErrs); err != nil {
+		return err
+	}
+	if len(fieldErrs) > 0 {
+		return errors.ErrInvalid.WithFields(fieldErrs...)
+	}
+
+	if vd, ok := asValidator(reflect.ValueOf(v)); ok {
+		if err := vd.Validate(); err != nil {
+			if _, isErr := err.(errors.Error); isErr {
+				return err
+			}
+			return errors.ErrInvalid.WithMsgf("%s", err.Error())
+		}
+	}
+	return nil
+}
+
+func validateValue(rv reflect.Value, prefix string, fieldErrs *[]errors.FieldError) error {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		var err error
+		walkFields(rv, func(sf reflect.StructField, fv reflect.Value) {
+			if err != nil {
+				return
+			}
+			name := prefix + fieldName(sf)
+
+			if rules := sf.Tag.Get("validate"); rules != "" && rules != "-" {
+				var reason string
+				reason, err = checkRules(fv, rules)
+				if reason != "" {
+					*fieldErrs = append(*fieldErrs, errors.FieldError{Field: name, Reason: reason})
+					return
+				}
+			}
+			if err == nil && fv.Type() != timeType {
+				err = validateValue(fv, name+".", fieldErrs)
+			}
+		})
+		return err
+
+	case reflect.Slice, reflect.Array:
+		if !hasStructElem(rv.Type()) {
+			return nil
+		}
+		base := strings.TrimSuffix(prefix, ".")
+		for i := 0; i < rv.Len(); i++ {
+			if err := validateValue(rv.Index(i), fmt.Sprintf("%s[%d].", base, i), fieldErrs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkRules returns the reason for the first failed rule. Invalid rules
+// are returned as error.
+func checkRules(fv reflect.Value, rules string) (string, error) {
+	isNil := (fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface) && fv.IsNil()
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+		if name == "required" {
+			if isNil || fv.IsZero() {
+				return "is required", nil
+			}
+			continue
+		}
+		if isNil {
+			// optional fields are validated only when set.
+			return "", nil
+		}
+
+		val := reflect.Indirect(fv)
+		switch name {
+		case "min", "max", "len":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return "", errors.ErrInternal.WithCausef("invalid validation rule '%s'", rule)
+			}
+			if reason := checkBound(val, name, limit, arg); reason != "" {
+				return reason, nil
+			}
+
+		case "oneof":
+			got := fmt.Sprint(val.Interface())
+			if got != "" && !contains(strings.Fields(arg), got) {
+				return fmt.Sprintf("must be one of [%s]", strings.Join(strings.Fields(arg), ", ")), nil
+			}
+
+		case "email":
+			if val.Kind() != reflect.String {
+				return "", errors.ErrInternal.WithCausef("email rule is not valid for %s", val.Type())
+			}
+			if s := val.String(); s != "" {
+				if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+					return "must be a valid email address", nil
+				}
+			}
+
+		case "":
+			continue
+
+		default:
+			return "", errors.ErrInternal.WithCausef("unknown validation rule '%s'", name)
+		}
+	}
+	return "", nil
+}
+
+func checkBound(val reflect.Value, rule string, limit float64, arg string) string {
+	var n float64
+	isLen := false
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(val.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = val.Float()
+	case reflect.String:
+		n, isLen = float64(utf8.RuneCountInString(val.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, isLen = float64(val.Len()), true
+	default:
+		return ""
+	}
+
+	what := "value"
+	if isLen || rule == "len" {
+		what = "length"
+	}
+
+	switch {
+	case rule == "min" && n < limit:
+		return fmt.Sprintf("%s must be at least %s", what, arg)
+	case rule == "max" && n > limit:
+		return fmt.Sprintf("%s must be at most %s", what, arg)
+	case rule == "len" && n != limit:
+		return fmt.Sprintf("length must be %s", arg)
+	}
+	return ""
+}
+
+// asValidator returns the Validator implementation of rv or any of the
+// values it points to.
+func asValidator(rv reflect.Value) (Validator, bool) {
+	for rv.IsValid() {
+		if vd, ok := rv.Interface().(Validator); ok {
+			return vd, true
+		}
+		if rv.Kind() != reflect.Ptr || rv.IsNil() {
+			break
+		}
+		rv = rv.Elem()
+	}
+	return nil, false
+}
+
+// fieldName returns the name used for the field in error details.
+func fieldName(sf reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "query", "path", "header"} {
+		if name := tagName(sf, tag); name != "" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+func hasStructElem(t reflect.Type) bool {
+	t = t.Elem()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
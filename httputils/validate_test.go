@@ -0,0 +1,106 @@
+package httputils_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/httputils"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type order struct {
+	Items   []item    `json:"items" validate:"min=1,max=3"`
+	Ship    *address  `json:"ship"`
+	Billing address   `json:"billing"`
+	Codes   []string  `json:"codes" validate:"len=2"`
+	Ratio   float64   `json:"ratio" validate:"max=1"`
+	Notes   *string   `json:"notes" validate:"min=5"`
+	Extra   *struct{} `json:"extra"`
+}
+
+type item struct {
+	SKU string `json:"sku" validate:"required,len=4"`
+	Qty uint   `json:"qty" validate:"min=1"`
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	short := "hi"
+	table := []struct {
+		title      string
+		v          interface{}
+		wantFields []errors.FieldError
+		wantErr    error
+	}{
+		{
+			title: "Valid",
+			v: order{
+				Items:   []item{{SKU: "ABCD", Qty: 1}},
+				Billing: address{City: "Bangalore"},
+				Codes:   []string{"a", "b"},
+			},
+		},
+		{
+			title: "Nested",
+			v: &order{
+				Items:   []item{{SKU: "ABCD", Qty: 1}, {SKU: "AB"}},
+				Ship:    &address{},
+				Billing: address{City: "Bangalore"},
+				Codes:   []string{"a"},
+				Ratio:   1.5,
+				Notes:   &short,
+			},
+			wantFields: []errors.FieldError{
+				{Field: "items[1].sku", Reason: "length must be 4"},
+				{Field: "items[1].qty", Reason: "value must be at least 1"},
+				{Field: "ship.city", Reason: "is required"},
+				{Field: "codes", Reason: "length must be 2"},
+				{Field: "ratio", Reason: "value must be at most 1"},
+				{Field: "notes", Reason: "length must be at least 5"},
+			},
+		},
+		{
+			title: "EmptySlice",
+			v: order{
+				Billing: address{City: "Bangalore"},
+				Codes:   []string{"a", "b"},
+			},
+			wantFields: []errors.FieldError{
+				{Field: "items", Reason: "length must be at least 1"},
+			},
+		},
+		{
+			title: "UnknownRule",
+			v: struct {
+				Name string `validate:"uppercase"`
+			}{},
+			wantErr: errors.ErrInternal,
+		},
+	}
+
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.title, func(t *testing.T) {
+			t.Parallel()
+
+			err := httputils.Validate(tt.v)
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+
+			case tt.wantFields != nil:
+				assert.ErrorIs(t, err, errors.ErrInvalid)
+				assert.Equal(t, tt.wantFields, errors.E(err).Fields())
+
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
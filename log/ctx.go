@@ -2,20 +2,88 @@ package log
 
 import (
 	"context"
-
-	"github.com/sirupsen/logrus"
+	"fmt"
 )
 
 type ctxKey string
 
-var fieldsKey = ctxKey("fields")
+var (
+	fieldsKey  = ctxKey("fields")
+	handlerKey = ctxKey("handler")
+)
+
+// Fields represents the key-value pairs attached to log entries. This is
+// an alias so that maps like logrus.Fields can be passed directly.
+type Fields = map[string]interface{}
+
+// InjectFields returns a new context with fields injected. Fields are
+// merged into the fields already present in the context, with the new
+// values taking precedence.
+func InjectFields(ctx context.Context, fields Fields) context.Context {
+	return context.WithValue(ctx, fieldsKey, mergeFields(fromCtx(ctx), fields))
+}
+
+// With returns a new context with the given key-value pairs merged into
+// the log fields of ctx. Keys must be strings, values can be anything.
+// For example, `log.With(ctx, "user_id", 10, "action", "login")`.
+func With(ctx context.Context, kv ...interface{}) context.Context {
+	return InjectFields(ctx, kvToFields(kv))
+}
 
-// InjectFields returns a new context with fields injected.
-func InjectFields(ctx context.Context, fields logrus.Fields) context.Context {
-	return context.WithValue(ctx, fieldsKey, fields)
+// ContextWithHandler returns a new context that routes the entries logged
+// with it to h instead of the global handler. Global level and overrides
+// are not applied to such entries and fatal entries do not exit the
+// process. This is mainly intended for capturing logs in tests (see the
+// logtest package).
+func ContextWithHandler(ctx context.Context, h Handler) context.Context {
+	return context.WithValue(ctx, handlerKey, h)
 }
 
-func fromCtx(ctx context.Context) logrus.Fields {
-	f, _ := ctx.Value(fieldsKey).(logrus.Fields)
+func handlerFromCtx(ctx context.Context) Handler {
+	if ctx == nil {
+		return nil
+	}
+	h, _ := ctx.Value(handlerKey).(Handler)
+	return h
+}
+
+func fromCtx(ctx context.Context) Fields {
+	if ctx == nil {
+		return nil
+	}
+	f, _ := ctx.Value(fieldsKey).(Fields)
 	return f
 }
+
+func mergeFields(base, extra Fields) Fields {
+	if len(extra) == 0 {
+		return base
+	}
+	merged := make(Fields, len(base)+len(extra))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+	return merged
+}
+
+const badKey = "!BADKEY"
+
+func kvToFields(kv []interface{}) Fields {
+	fields := make(Fields, (len(kv)+1)/2)
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 >= len(kv) {
+			fields[badKey] = kv[i]
+			break
+		}
+
+		key, isStr := kv[i].(string)
+		if !isStr {
+			key = fmt.Sprint(kv[i])
+		}
+		fields[key] = kv[i+1]
+	}
+	return fields
+}
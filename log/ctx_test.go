@@ -0,0 +1,56 @@
+package log
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWith(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		title string
+		ctx   context.Context
+		kv    []interface{}
+		want  Fields
+	}{
+		{
+			title: "EmptyContext",
+			ctx:   context.Background(),
+			kv:    []interface{}{"a", 1},
+			want:  Fields{"a": 1},
+		},
+		{
+			title: "MergesExisting",
+			ctx:   InjectFields(context.Background(), logrus.Fields{"a": 1, "b": 2}),
+			kv:    []interface{}{"b", 3, "c", 4},
+			want:  Fields{"a": 1, "b": 3, "c": 4},
+		},
+		{
+			title: "OddArgs",
+			ctx:   context.Background(),
+			kv:    []interface{}{"a", 1, "dangling"},
+			want:  Fields{"a": 1, badKey: "dangling"},
+		},
+		{
+			title: "NonStringKey",
+			ctx:   context.Background(),
+			kv:    []interface{}{10, "ten"},
+			want:  Fields{"10": "ten"},
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.title, func(t *testing.T) {
+			parent := fromCtx(tt.ctx)
+			before := len(parent)
+
+			got := fromCtx(With(tt.ctx, tt.kv...))
+			assert.Equal(t, tt.want, got)
+			assert.Len(t, parent, before, "parent fields must not be modified")
+		})
+	}
+}
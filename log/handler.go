@@ -0,0 +1,132 @@
+package log
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Entry represents a single log record passed to a Handler.
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	Fields  Fields
+
+	// Template is the format string (or the message itself for non
+	// formatted logs) that identifies the call-site of the entry.
+	Template string
+}
+
+// Handler is the backend that writes log entries. Handlers receive only
+// the entries that pass the global level check done by the log package.
+type Handler interface {
+	Enabled(ctx context.Context, lvl Level) bool
+	Handle(ctx context.Context, e Entry) error
+}
+
+// NewSlogHandler returns a Handler that writes entries using the given
+// slog.Handler. Do not pass the handler of a logger returned by Slog()
+// as it would result in infinite recursion.
+func NewSlogHandler(h slog.Handler) Handler {
+	return &slogBackend{h: h}
+}
+
+// NewLogrusHandler returns a Handler that writes entries using the given
+// logrus logger. Level configured on the logger is also respected.
+func NewLogrusHandler(lg *logrus.Logger) Handler {
+	return &logrusBackend{lg: lg}
+}
+
+func newBackend(backend, format string, w io.Writer) Handler {
+	if backend == "logrus" {
+		lg := logrus.New()
+		lg.SetOutput(w)
+		lg.SetLevel(logrus.TraceLevel)
+		lg.SetFormatter(&logrus.TextFormatter{})
+		if format == "json" {
+			lg.SetFormatter(&logrus.JSONFormatter{})
+		}
+		return NewLogrusHandler(lg)
+	}
+
+	opts := &slog.HandlerOptions{
+		Level:       slog.Level(DebugLevel),
+		ReplaceAttr: replaceLevelAttr,
+	}
+	if format == "json" {
+		return NewSlogHandler(slog.NewJSONHandler(w, opts))
+	}
+	return NewSlogHandler(slog.NewTextHandler(w, opts))
+}
+
+type slogBackend struct {
+	h slog.Handler
+}
+
+func (sb *slogBackend) Enabled(ctx context.Context, lvl Level) bool {
+	return sb.h.Enabled(ctx, slog.Level(lvl))
+}
+
+func (sb *slogBackend) Handle(ctx context.Context, e Entry) error {
+	rec := slog.NewRecord(e.Time, slog.Level(e.Level), e.Message, 0)
+	for _, k := range sortedKeys(e.Fields) {
+		rec.AddAttrs(slog.Any(k, e.Fields[k]))
+	}
+	return sb.h.Handle(ctx, rec)
+}
+
+type logrusBackend struct {
+	lg *logrus.Logger
+}
+
+func (lb *logrusBackend) Enabled(_ context.Context, lvl Level) bool {
+	return lb.lg.IsLevelEnabled(toLogrusLevel(lvl))
+}
+
+func (lb *logrusBackend) Handle(ctx context.Context, e Entry) error {
+	lb.lg.WithContext(ctx).
+		WithTime(e.Time).
+		WithFields(logrus.Fields(e.Fields)).
+		Log(toLogrusLevel(e.Level), e.Message)
+	return nil
+}
+
+func toLogrusLevel(lvl Level) logrus.Level {
+	switch {
+	case lvl < InfoLevel:
+		return logrus.DebugLevel
+	case lvl < WarnLevel:
+		return logrus.InfoLevel
+	case lvl < ErrorLevel:
+		return logrus.WarnLevel
+	case lvl < FatalLevel:
+		return logrus.ErrorLevel
+	default:
+		return logrus.FatalLevel
+	}
+}
+
+// replaceLevelAttr renders the level attribute in lower-case and names
+// the fatal level instead of rendering it as "ERROR+4".
+func replaceLevelAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.LevelKey {
+		if lvl, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(Level(lvl).String())
+		}
+	}
+	return a
+}
+
+func sortedKeys(fields Fields) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,55 @@
+package log
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Level represents the severity of a log entry. Values are compatible
+// with slog.Level so that conversion is a simple type cast.
+type Level int8
+
+// Supported log levels.
+const (
+	DebugLevel = Level(slog.LevelDebug)
+	InfoLevel  = Level(slog.LevelInfo)
+	WarnLevel  = Level(slog.LevelWarn)
+	ErrorLevel = Level(slog.LevelError)
+	FatalLevel = Level(slog.LevelError + 4)
+)
+
+// ParseLevel parses the level name (case-insensitive) into Level.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug", "trace":
+		return DebugLevel, nil
+	case "info":
+		return InfoLevel, nil
+	case "warn", "warning":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	case "fatal", "panic":
+		return FatalLevel, nil
+	default:
+		return InfoLevel, fmt.Errorf("unknown log level '%s'", s)
+	}
+}
+
+func (lvl Level) String() string {
+	switch lvl {
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarnLevel:
+		return "warn"
+	case ErrorLevel:
+		return "error"
+	case FatalLevel:
+		return "fatal"
+	default:
+		return slog.Level(lvl).String()
+	}
+}
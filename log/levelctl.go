@@ -0,0 +1,158 @@
+package log
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var nameKey = ctxKey("logger_name")
+
+var (
+	overrides atomic.Pointer[map[string]Level]
+
+	revertMu sync.Mutex
+	reverts  = map[string]*pendingRevert{}
+)
+
+type pendingRevert struct {
+	timer   *time.Timer
+	prev    Level
+	hadPrev bool
+}
+
+// Named returns a new context with the logger name set. Nested names are
+// joined using "." (e.g., "store.postgres"). The name is included in the
+// entries as the "logger" field and is used to look up level overrides
+// set using SetLoggerLevel().
+func Named(ctx context.Context, name string) context.Context {
+	if parent := nameFromCtx(ctx); parent != "" {
+		name = parent + "." + name
+	}
+	ctx = context.WithValue(ctx, nameKey, name)
+	return InjectFields(ctx, Fields{"logger": name})
+}
+
+// SetLoggerLevel overrides the level for the named logger and all of its
+// children. Empty name sets the global level.
+func SetLoggerLevel(name string, lvl Level) {
+	cancelRevert(name)
+	setLevel(name, lvl)
+}
+
+// ResetLoggerLevel removes the level override for the named logger so
+// that it inherits the level from its parent again.
+func ResetLoggerLevel(name string) {
+	cancelRevert(name)
+	updateOverrides(func(m map[string]Level) { delete(m, name) })
+}
+
+// SetLevelFor sets the level for the named logger (empty name for the
+// global level) and reverts it to the previous value after d.
+func SetLevelFor(name string, lvl Level, d time.Duration) {
+	revertMu.Lock()
+	defer revertMu.Unlock()
+
+	pr, exists := reverts[name]
+	if exists {
+		pr.timer.Stop()
+	} else {
+		pr = &pendingRevert{}
+		pr.prev, pr.hadPrev = currentLevel(name)
+		reverts[name] = pr
+	}
+
+	setLevel(name, lvl)
+	pr.timer = time.AfterFunc(d, func() {
+		revertMu.Lock()
+		defer revertMu.Unlock()
+		if reverts[name] != pr {
+			return
+		}
+		delete(reverts, name)
+
+		if pr.hadPrev {
+			setLevel(name, pr.prev)
+		} else {
+			updateOverrides(func(m map[string]Level) { delete(m, name) })
+		}
+	})
+}
+
+// LoggerLevels returns the current level overrides keyed by logger name.
+func LoggerLevels() map[string]Level {
+	current := overrides.Load()
+	res := map[string]Level{}
+	if current != nil {
+		for k, v := range *current {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// LevelOf returns the effective level for the named logger.
+func LevelOf(name string) Level {
+	current := overrides.Load()
+	if current == nil || len(*current) == 0 {
+		return GetLevel()
+	}
+
+	for name != "" {
+		if lvl, found := (*current)[name]; found {
+			return lvl
+		}
+		idx := strings.LastIndex(name, ".")
+		if idx < 0 {
+			break
+		}
+		name = name[:idx]
+	}
+	return GetLevel()
+}
+
+func nameFromCtx(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	name, _ := ctx.Value(nameKey).(string)
+	return name
+}
+
+func currentLevel(name string) (Level, bool) {
+	if name == "" {
+		return GetLevel(), true
+	}
+	lvl, found := LoggerLevels()[name]
+	return lvl, found
+}
+
+func setLevel(name string, lvl Level) {
+	if name == "" {
+		minLevel.Store(int32(lvl))
+		return
+	}
+	updateOverrides(func(m map[string]Level) { m[name] = lvl })
+}
+
+func cancelRevert(name string) {
+	revertMu.Lock()
+	defer revertMu.Unlock()
+	if pr, exists := reverts[name]; exists {
+		pr.timer.Stop()
+		delete(reverts, name)
+	}
+}
+
+var overridesMu sync.Mutex
+
+func updateOverrides(fn func(m map[string]Level)) {
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+
+	updated := LoggerLevels()
+	fn(updated)
+	overrides.Store(&updated)
+}
@@ -0,0 +1,46 @@
+package log
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelOf(t *testing.T) {
+	SetLevel(InfoLevel)
+	SetLoggerLevel("store", DebugLevel)
+	SetLoggerLevel("store.cache", ErrorLevel)
+	defer func() {
+		ResetLoggerLevel("store")
+		ResetLoggerLevel("store.cache")
+	}()
+
+	assert.Equal(t, InfoLevel, LevelOf(""))
+	assert.Equal(t, InfoLevel, LevelOf("http"))
+	assert.Equal(t, DebugLevel, LevelOf("store"))
+	assert.Equal(t, DebugLevel, LevelOf("store.postgres"))
+	assert.Equal(t, ErrorLevel, LevelOf("store.cache.redis"))
+
+	ctx := Named(Named(context.Background(), "store"), "postgres")
+	assert.Equal(t, "store.postgres", nameFromCtx(ctx))
+	assert.Equal(t, "store.postgres", fromCtx(ctx)["logger"])
+	assert.True(t, Enabled(ctx, DebugLevel))
+	assert.False(t, Enabled(context.Background(), DebugLevel))
+}
+
+func TestSetLevelFor(t *testing.T) {
+	SetLevel(WarnLevel)
+	defer SetLevel(InfoLevel)
+
+	SetLevelFor("", DebugLevel, 20*time.Millisecond)
+	SetLevelFor("", ErrorLevel, 20*time.Millisecond)
+	SetLevelFor("db", DebugLevel, 20*time.Millisecond)
+	assert.Equal(t, ErrorLevel, GetLevel())
+	assert.Equal(t, DebugLevel, LevelOf("db"))
+
+	assert.Eventually(t, func() bool {
+		return GetLevel() == WarnLevel && len(LoggerLevels()) == 0
+	}, time.Second, 5*time.Millisecond)
+}
@@ -2,47 +2,276 @@ package log
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	minLevel atomic.Int32
+	handler  atomic.Pointer[handlerBox]
+	osExit   = os.Exit
 
-	"github.com/sirupsen/logrus"
+	sinksMu   sync.Mutex
+	openSinks []io.Closer
 )
 
-var lg = logrus.New()
+type handlerBox struct{ h Handler }
+
+func init() {
+	minLevel.Store(int32(InfoLevel))
+	SetHandler(newBackend("slog", "text", os.Stderr))
+}
+
+// Option can be passed to Setup() or Configure() to customise the logger.
+type Option func(opts *options)
+
+type options struct {
+	backend  string
+	output   io.Writer
+	outputs  []string
+	handler  Handler
+	sampling *SamplingOptions
+	redact   *RedactOptions
+}
+
+// WithBackend sets the backend used for writing logs. Supported values
+// are "slog" (default) and "logrus".
+func WithBackend(backend string) Option {
+	return func(opts *options) { opts.backend = backend }
+}
+
+// WithOutput sets the writer to which the logs are written. Defaults to
+// os.Stderr.
+func WithOutput(w io.Writer) Option {
+	return func(opts *options) { opts.output = w }
+}
+
+// WithOutputs sets the outputs (sinks) to which the logs are written.
+// Each spec can be 'stderr', 'stdout', a file path or URL, 'syslog://'
+// or 'journald://'. Specs accept 'level' and 'format' query params to
+// override the values for the sink. Files support rotation using
+// 'max_size', 'max_age', 'max_backups' and 'compress' query params.
+// For example, "file:///var/log/app.log?max_size=100MB&compress=true".
+func WithOutputs(specs ...string) Option {
+	return func(opts *options) { opts.outputs = append(opts.outputs, specs...) }
+}
+
+// WithHandler sets a custom handler. Backend, format and output options
+// are ignored when this is set.
+func WithHandler(h Handler) Option {
+	return func(opts *options) { opts.handler = h }
+}
+
+// Setup configures the global logger instance with level, format and
+// the given options. Failures (e.g., invalid outputs) are reported on
+// stderr and the current logger is retained. Use Configure() to handle
+// the errors.
+func Setup(level, format string, opts ...Option) {
+	if err := Configure(level, format, opts...); err != nil {
+		fmt.Fprintf(os.Stderr, "log: setup failed: %v\n", err)
+	}
+}
 
-// Setup configures the global logger instance with level and
-// formatter.
-func Setup(level, format string) {
-	lvl, err := logrus.ParseLevel(level)
+// Configure is same as Setup() but returns the error instead. It also
+// sets the default slog logger to one that writes through the log
+// package (see Slog()). Sinks opened by previous Setup() or Configure()
+// calls are closed.
+func Configure(level, format string, opts ...Option) error {
+	o := options{backend: "slog", output: os.Stderr}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	lvl, err := ParseLevel(level)
 	if err != nil {
-		lvl = logrus.WarnLevel
+		lvl = WarnLevel
+	}
+
+	h := o.handler
+	var closers []io.Closer
+	if h == nil {
+		h, closers, err = buildHandler(o, format)
+		if err != nil {
+			return err
+		}
+	}
+
+	if o.redact != nil {
+		h = NewRedactor(h, *o.redact)
+	}
+	if o.sampling != nil {
+		h = NewSampler(h, *o.sampling)
+		// closed before the sinks so that the final summary is written.
+		closers = append([]io.Closer{h.(io.Closer)}, closers...)
+	}
+
+	SetLevel(lvl)
+	SetHandler(h)
+	slog.SetDefault(Slog())
+
+	sinksMu.Lock()
+	prevSinks := openSinks
+	openSinks = closers
+	sinksMu.Unlock()
+	for _, c := range prevSinks {
+		_ = c.Close()
+	}
+	return nil
+}
+
+func buildHandler(o options, format string) (Handler, []io.Closer, error) {
+	if len(o.outputs) == 0 {
+		return newBackend(o.backend, format, o.output), nil, nil
+	}
+
+	var closers []io.Closer
+	closeAll := func() {
+		for _, c := range closers {
+			_ = c.Close()
+		}
+	}
+
+	var handlers []Handler
+	for _, spec := range o.outputs {
+		s, err := parseOutput(spec)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		if s.closer != nil {
+			closers = append(closers, s.closer)
+		}
+
+		sinkFormat := format
+		if s.format != "" {
+			sinkFormat = s.format
+		}
+
+		lw, _ := s.writer.(levelWriter)
+		handlers = append(handlers, &sinkHandler{
+			min:     s.level,
+			backend: newBackend(o.backend, sinkFormat, s.writer),
+			lw:      lw,
+		})
 	}
-	lg.SetLevel(lvl)
-	lg.SetFormatter(&logrus.TextFormatter{})
-	if format == "json" {
-		lg.SetFormatter(&logrus.JSONFormatter{})
+	return MultiHandler(handlers...), closers, nil
+}
+
+// SetHandler replaces the global handler.
+func SetHandler(h Handler) { handler.Store(&handlerBox{h: h}) }
+
+// GetHandler returns the global handler.
+func GetHandler() Handler { return currentHandler() }
+
+// SetLevel sets the global minimum level of the entries that are logged.
+// Overrides set using SetLoggerLevel() take precedence for named loggers.
+func SetLevel(lvl Level) { SetLoggerLevel("", lvl) }
+
+// GetLevel returns the current minimum log level.
+func GetLevel() Level { return Level(minLevel.Load()) }
+
+// Enabled returns true if an entry at the given level would be logged.
+func Enabled(ctx context.Context, lvl Level) bool {
+	if h := handlerFromCtx(ctx); h != nil {
+		return h.Enabled(ctx, lvl)
 	}
+	return lvl >= LevelOf(nameFromCtx(ctx)) && currentHandler().Enabled(ctx, lvl)
 }
 
 func Debugf(ctx context.Context, format string, args ...interface{}) {
-	fields := fromCtx(ctx)
-	lg.WithContext(ctx).WithFields(fields).Debugf(format, args...)
+	logf(ctx, DebugLevel, format, args)
 }
 
 func Infof(ctx context.Context, format string, args ...interface{}) {
-	fields := fromCtx(ctx)
-	lg.WithContext(ctx).WithFields(fields).Infof(format, args...)
+	logf(ctx, InfoLevel, format, args)
 }
 
 func Warnf(ctx context.Context, format string, args ...interface{}) {
-	fields := fromCtx(ctx)
-	lg.WithContext(ctx).WithFields(fields).Warnf(format, args...)
+	logf(ctx, WarnLevel, format, args)
 }
 
 func Errorf(ctx context.Context, format string, args ...interface{}) {
-	fields := fromCtx(ctx)
-	lg.WithContext(ctx).WithFields(fields).Errorf(format, args...)
+	logf(ctx, ErrorLevel, format, args)
 }
 
 func Fatalf(ctx context.Context, format string, args ...interface{}) {
-	fields := fromCtx(ctx)
-	lg.WithContext(ctx).WithFields(fields).Fatalf(format, args...)
+	logf(ctx, FatalLevel, format, args)
+}
+
+// Debug logs msg along with the key-value pairs at debug level. Fields
+// in ctx are included as well.
+func Debug(ctx context.Context, msg string, kv ...interface{}) {
+	logkv(ctx, DebugLevel, msg, kv)
+}
+
+// Info logs msg along with the key-value pairs at info level.
+func Info(ctx context.Context, msg string, kv ...interface{}) {
+	logkv(ctx, InfoLevel, msg, kv)
+}
+
+// Warn logs msg along with the key-value pairs at warn level.
+func Warn(ctx context.Context, msg string, kv ...interface{}) {
+	logkv(ctx, WarnLevel, msg, kv)
+}
+
+// Error logs msg along with the key-value pairs at error level.
+func Error(ctx context.Context, msg string, kv ...interface{}) {
+	logkv(ctx, ErrorLevel, msg, kv)
+}
+
+// Fatal logs msg along with the key-value pairs at fatal level and
+// exits the process.
+func Fatal(ctx context.Context, msg string, kv ...interface{}) {
+	logkv(ctx, FatalLevel, msg, kv)
+}
+
+func logf(ctx context.Context, lvl Level, format string, args []interface{}) {
+	if !Enabled(ctx, lvl) {
+		exitIfFatal(ctx, lvl)
+		return
+	}
+	write(ctx, lvl, format, fmt.Sprintf(format, args...), fromCtx(ctx))
+}
+
+func logkv(ctx context.Context, lvl Level, msg string, kv []interface{}) {
+	if !Enabled(ctx, lvl) {
+		exitIfFatal(ctx, lvl)
+		return
+	}
+	write(ctx, lvl, msg, msg, mergeFields(fromCtx(ctx), kvToFields(kv)))
+}
+
+func write(ctx context.Context, lvl Level, template, msg string, fields Fields) {
+	e := Entry{
+		Time:     time.Now(),
+		Level:    lvl,
+		Message:  msg,
+		Template: template,
+		Fields:   fields,
+	}
+	if err := handlerFor(ctx).Handle(ctx, e); err != nil {
+		fmt.Fprintf(os.Stderr, "log: failed to write entry: %v\n", err)
+	}
+	exitIfFatal(ctx, lvl)
+}
+
+func exitIfFatal(ctx context.Context, lvl Level) {
+	if lvl >= FatalLevel && handlerFromCtx(ctx) == nil {
+		osExit(1)
+	}
+}
+
+func currentHandler() Handler { return handler.Load().h }
+
+// handlerFor returns the handler from ctx if set, otherwise the global one.
+func handlerFor(ctx context.Context) Handler {
+	if h := handlerFromCtx(ctx); h != nil {
+		return h
+	}
+	return currentHandler()
 }
@@ -0,0 +1,44 @@
+package log
+
+import "context"
+
+// Logger is a logger bound to a context. Use FromContext() to obtain one
+// for code that cannot pass the context to every log call.
+type Logger struct {
+	ctx context.Context
+}
+
+// FromContext returns a Logger that logs with the fields in ctx.
+func FromContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &Logger{ctx: ctx}
+}
+
+// With returns a new Logger with the key-value pairs merged into its
+// fields. See log.With() for details.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	return &Logger{ctx: With(l.ctx, kv...)}
+}
+
+// Named returns a new Logger with the name appended to the logger name.
+// See log.Named() for details.
+func (l *Logger) Named(name string) *Logger {
+	return &Logger{ctx: Named(l.ctx, name)}
+}
+
+// Context returns the context carrying the logger fields.
+func (l *Logger) Context() context.Context { return l.ctx }
+
+func (l *Logger) Debug(msg string, kv ...interface{}) { Debug(l.ctx, msg, kv...) }
+func (l *Logger) Info(msg string, kv ...interface{})  { Info(l.ctx, msg, kv...) }
+func (l *Logger) Warn(msg string, kv ...interface{})  { Warn(l.ctx, msg, kv...) }
+func (l *Logger) Error(msg string, kv ...interface{}) { Error(l.ctx, msg, kv...) }
+func (l *Logger) Fatal(msg string, kv ...interface{}) { Fatal(l.ctx, msg, kv...) }
+
+func (l *Logger) Debugf(format string, args ...interface{}) { Debugf(l.ctx, format, args...) }
+func (l *Logger) Infof(format string, args ...interface{})  { Infof(l.ctx, format, args...) }
+func (l *Logger) Warnf(format string, args ...interface{})  { Warnf(l.ctx, format, args...) }
+func (l *Logger) Errorf(format string, args ...interface{}) { Errorf(l.ctx, format, args...) }
+func (l *Logger) Fatalf(format string, args ...interface{}) { Fatalf(l.ctx, format, args...) }
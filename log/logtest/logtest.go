@@ -0,0 +1,136 @@
+// Package logtest provides helpers for asserting on the logs written
+// using the log package in tests.
+package logtest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spy16/moonshot/log"
+)
+
+// Entry represents a captured log entry.
+type Entry struct {
+	Time    time.Time
+	Level   log.Level
+	Message string
+	Fields  log.Fields
+}
+
+// Capture is same as CaptureContext() with context.Background() as the
+// parent.
+func Capture(t testing.TB) (context.Context, *Recorder) {
+	t.Helper()
+	return CaptureContext(context.Background(), t)
+}
+
+// CaptureContext returns a child of ctx that routes the logs written with
+// it (or its children) to the returned Recorder. Values of the parent
+// (e.g., log fields, deadline) are retained. Since the capture is scoped
+// through the context, it is safe to use in parallel tests. Recording
+// stops when the test completes and the captured entries are dumped if it
+// failed.
+func CaptureContext(ctx context.Context, t testing.TB) (context.Context, *Recorder) {
+	t.Helper()
+
+	rec := newRecorder(t)
+	return log.ContextWithHandler(ctx, rec), rec
+}
+
+// CaptureGlobal redirects the global logger to the returned Recorder (at
+// all levels) until the test completes. Use this when the code under test
+// logs with contexts that are not derived from the test (e.g., background
+// goroutines). Since it changes the global logger, it must not be used in
+// parallel tests.
+func CaptureGlobal(t testing.TB) *Recorder {
+	t.Helper()
+
+	rec := newRecorder(t)
+	prevHandler, prevLevel := log.GetHandler(), log.GetLevel()
+	log.SetHandler(rec)
+	log.SetLevel(log.DebugLevel)
+	t.Cleanup(func() {
+		log.SetHandler(prevHandler)
+		log.SetLevel(prevLevel)
+	})
+	return rec
+}
+
+func newRecorder(t testing.TB) *Recorder {
+	rec := &Recorder{}
+	t.Cleanup(func() {
+		rec.mu.Lock()
+		rec.closed = true
+		entries := append([]Entry(nil), rec.entries...)
+		rec.mu.Unlock()
+
+		if t.Failed() {
+			for _, e := range entries {
+				t.Logf("[%s] %s %v", e.Level, e.Message, e.Fields)
+			}
+		}
+	})
+	return rec
+}
+
+// Recorder is a log.Handler that records the entries in memory.
+type Recorder struct {
+	mu      sync.Mutex
+	closed  bool
+	entries []Entry
+}
+
+// Enabled returns true for all levels.
+func (rec *Recorder) Enabled(context.Context, log.Level) bool { return true }
+
+// Handle records the entry.
+func (rec *Recorder) Handle(_ context.Context, e log.Entry) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if !rec.closed {
+		rec.entries = append(rec.entries, Entry{
+			Time:    e.Time,
+			Level:   e.Level,
+			Message: e.Message,
+			Fields:  e.Fields,
+		})
+	}
+	return nil
+}
+
+// Entries returns all the entries captured so far.
+func (rec *Recorder) Entries() []Entry {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]Entry(nil), rec.entries...)
+}
+
+// Filter returns the captured entries at the given level.
+func (rec *Recorder) Filter(lvl log.Level) []Entry {
+	var res []Entry
+	for _, e := range rec.Entries() {
+		if e.Level == lvl {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// Messages returns the messages of all the captured entries.
+func (rec *Recorder) Messages() []string {
+	var res []string
+	for _, e := range rec.Entries() {
+		res = append(res, e.Message)
+	}
+	return res
+}
+
+// Reset clears the captured entries.
+func (rec *Recorder) Reset() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.entries = nil
+}
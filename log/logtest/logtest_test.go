@@ -0,0 +1,78 @@
+package logtest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spy16/moonshot/log"
+	"github.com/spy16/moonshot/log/logtest"
+)
+
+func TestCapture(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"first", "second"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, rec := logtest.Capture(t)
+			ctx = log.With(ctx, "test", name)
+
+			log.Debugf(ctx, "hello %s", name)
+			log.Error(ctx, "failed", "attempt", 2)
+			log.Slog().InfoContext(ctx, "via slog")
+			log.Fatal(ctx, "not exiting")
+
+			assert.Equal(t, []string{"hello " + name, "failed", "via slog", "not exiting"}, rec.Messages())
+
+			errs := rec.Filter(log.ErrorLevel)
+			if assert.Len(t, errs, 1) {
+				assert.Equal(t, log.Fields{"test": name, "attempt": 2}, errs[0].Fields)
+			}
+
+			rec.Reset()
+			assert.Empty(t, rec.Entries())
+		})
+	}
+}
+
+func TestCaptureContext(t *testing.T) {
+	t.Parallel()
+
+	type ctxKey string
+
+	parent := context.WithValue(context.Background(), ctxKey("k"), "v")
+	parent = log.With(parent, "component", "store")
+
+	ctx, rec := logtest.CaptureContext(parent, t)
+	assert.Equal(t, "v", ctx.Value(ctxKey("k")))
+
+	log.Info(ctx, "loaded", "count", 3)
+	entries := rec.Entries()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, log.Fields{"component": "store", "count": 3}, entries[0].Fields)
+	}
+}
+
+func TestCaptureGlobal(t *testing.T) {
+	prev, prevLevel := log.GetHandler(), log.GetLevel()
+
+	t.Run("Capture", func(t *testing.T) {
+		rec := logtest.CaptureGlobal(t)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			log.Debug(context.Background(), "from goroutine")
+		}()
+		<-done
+
+		assert.Equal(t, []string{"from goroutine"}, rec.Messages())
+	})
+
+	assert.Equal(t, prev, log.GetHandler(), "global handler must be restored")
+	assert.Equal(t, prevLevel, log.GetLevel())
+}
@@ -0,0 +1,190 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sink represents a parsed '--log-output' value.
+type sink struct {
+	writer io.Writer
+	closer io.Closer
+	level  *Level
+	format string
+}
+
+// parseOutput parses output spec into a sink. Supported specs:
+//
+//	stderr, stdout
+//	file:///var/log/app.log?max_size=100MB&max_age=24h&max_backups=7&compress=true
+//	/var/log/app.log (same as file://)
+//	syslog://?tag=app&facility=local0
+//	journald://?tag=app
+//
+// All specs accept 'level' and 'format' query params to override the
+// global values for the sink (e.g., "stderr?level=warn&format=json").
+func parseOutput(spec string) (*sink, error) {
+	spec = strings.TrimSpace(spec)
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log output '%s': %w", spec, err)
+	}
+
+	q := u.Query()
+	s := &sink{format: q.Get("format")}
+	if lvlStr := q.Get("level"); lvlStr != "" {
+		lvl, err := ParseLevel(lvlStr)
+		if err != nil {
+			return nil, err
+		}
+		s.level = &lvl
+	}
+
+	switch u.Scheme {
+	case "":
+		switch u.Path {
+		case "stderr":
+			s.writer = os.Stderr
+		case "stdout":
+			s.writer = os.Stdout
+		default:
+			return fileSink(s, u.Path, q)
+		}
+
+	case "file":
+		path := u.Opaque
+		if path == "" {
+			path = u.Host + u.Path
+		}
+		return fileSink(s, path, q)
+
+	case "syslog", "journald":
+		w, err := dialSyslog(u.Scheme, q.Get("tag"), q.Get("facility"))
+		if err != nil {
+			return nil, err
+		}
+		s.writer, s.closer = w, w
+
+	default:
+		return nil, fmt.Errorf("unsupported log output '%s'", spec)
+	}
+
+	return s, nil
+}
+
+func fileSink(s *sink, path string, q url.Values) (*sink, error) {
+	if path == "" {
+		return nil, errors.New("file path must be specified for file log output")
+	}
+
+	rf := &RotatingFile{
+		Path:     path,
+		Compress: q.Get("compress") == "true",
+	}
+
+	if v := q.Get("max_size"); v != "" {
+		size, err := parseSize(v)
+		if err != nil {
+			return nil, err
+		}
+		rf.MaxSize = size
+	}
+
+	if v := q.Get("max_age"); v != "" {
+		age, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_age '%s': %w", v, err)
+		}
+		rf.MaxAge = age
+	}
+
+	if v := q.Get("max_backups"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_backups '%s': %w", v, err)
+		}
+		rf.MaxBackups = n
+	}
+
+	s.writer, s.closer = rf, rf
+	return s, nil
+}
+
+// levelWriter is implemented by writers that need the level of the entry
+// being written (e.g., syslog).
+type levelWriter interface {
+	io.Writer
+	setLevel(lvl Level)
+}
+
+type levelWriteCloser interface {
+	levelWriter
+	io.Closer
+}
+
+// sinkHandler writes entries to the sink using the backend handler. For
+// level-aware writers, the entry level is set before formatting it.
+type sinkHandler struct {
+	mu      sync.Mutex
+	min     *Level
+	backend Handler
+	lw      levelWriter
+}
+
+func (sh *sinkHandler) Enabled(ctx context.Context, lvl Level) bool {
+	if sh.min != nil && lvl < *sh.min {
+		return false
+	}
+	return sh.backend.Enabled(ctx, lvl)
+}
+
+func (sh *sinkHandler) Handle(ctx context.Context, e Entry) error {
+	if sh.lw == nil {
+		return sh.backend.Handle(ctx, e)
+	}
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.lw.setLevel(e.Level)
+	return sh.backend.Handle(ctx, e)
+}
+
+// MultiHandler returns a Handler that fans out the entries to all the
+// given handlers that are enabled for the entry level.
+func MultiHandler(handlers ...Handler) Handler {
+	if len(handlers) == 1 {
+		return handlers[0]
+	}
+	return multiHandler(handlers)
+}
+
+type multiHandler []Handler
+
+func (mh multiHandler) Enabled(ctx context.Context, lvl Level) bool {
+	for _, h := range mh {
+		if h.Enabled(ctx, lvl) {
+			return true
+		}
+	}
+	return false
+}
+
+func (mh multiHandler) Handle(ctx context.Context, e Entry) error {
+	var errs []error
+	for _, h := range mh {
+		if h.Enabled(ctx, e.Level) {
+			if err := h.Handle(ctx, e); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package log
+
+import "fmt"
+
+func dialSyslog(kind, tag, facility string) (levelWriteCloser, error) {
+	return nil, fmt.Errorf("%s log output is not supported on this platform", kind)
+}
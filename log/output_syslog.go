@@ -0,0 +1,139 @@
+//go:build !windows && !plan9
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/syslog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const journaldSocket = "/run/systemd/journal/socket"
+
+var facilities = map[string]syslog.Priority{
+	"kern": syslog.LOG_KERN, "user": syslog.LOG_USER, "daemon": syslog.LOG_DAEMON,
+	"auth": syslog.LOG_AUTH, "syslog": syslog.LOG_SYSLOG,
+	"local0": syslog.LOG_LOCAL0, "local1": syslog.LOG_LOCAL1,
+	"local2": syslog.LOG_LOCAL2, "local3": syslog.LOG_LOCAL3,
+	"local4": syslog.LOG_LOCAL4, "local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6, "local7": syslog.LOG_LOCAL7,
+}
+
+func dialSyslog(kind, tag, facility string) (levelWriteCloser, error) {
+	if tag == "" {
+		tag = filepath.Base(os.Args[0])
+	}
+
+	if kind == "journald" {
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journaldSocket, Net: "unixgram"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to journald: %w", err)
+		}
+		return &journaldWriter{conn: conn, tag: tag}, nil
+	}
+
+	prio := syslog.LOG_USER
+	if facility != "" {
+		p, found := facilities[strings.ToLower(facility)]
+		if !found {
+			return nil, fmt.Errorf("unknown syslog facility '%s'", facility)
+		}
+		prio = p
+	}
+
+	w, err := syslog.New(prio|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return &syslogWriter{w: w}, nil
+}
+
+type syslogWriter struct {
+	w   *syslog.Writer
+	lvl Level
+}
+
+func (sw *syslogWriter) setLevel(lvl Level) { sw.lvl = lvl }
+
+func (sw *syslogWriter) Write(p []byte) (int, error) {
+	msg := string(bytes.TrimRight(p, "\n"))
+
+	var err error
+	switch {
+	case sw.lvl < InfoLevel:
+		err = sw.w.Debug(msg)
+	case sw.lvl < WarnLevel:
+		err = sw.w.Info(msg)
+	case sw.lvl < ErrorLevel:
+		err = sw.w.Warning(msg)
+	case sw.lvl < FatalLevel:
+		err = sw.w.Err(msg)
+	default:
+		err = sw.w.Crit(msg)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (sw *syslogWriter) Close() error { return sw.w.Close() }
+
+// journaldWriter writes entries to journald using its native protocol.
+// See https://systemd.io/JOURNAL_NATIVE_PROTOCOL/
+type journaldWriter struct {
+	conn *net.UnixConn
+	tag  string
+	lvl  Level
+}
+
+func (jw *journaldWriter) setLevel(lvl Level) { jw.lvl = lvl }
+
+func (jw *journaldWriter) Write(p []byte) (int, error) {
+	var buf bytes.Buffer
+	writeJournalField(&buf, "PRIORITY", strconv.Itoa(journalPriority(jw.lvl)))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", jw.tag)
+	writeJournalField(&buf, "MESSAGE", string(bytes.TrimRight(p, "\n")))
+
+	if _, err := jw.conn.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (jw *journaldWriter) Close() error { return jw.conn.Close() }
+
+func writeJournalField(buf *bytes.Buffer, key, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(buf, "%s=%s\n", key, value)
+		return
+	}
+
+	// values with newlines must be written in the binary-safe format.
+	buf.WriteString(key)
+	buf.WriteByte('\n')
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+func journalPriority(lvl Level) int {
+	switch {
+	case lvl < InfoLevel:
+		return 7
+	case lvl < WarnLevel:
+		return 6
+	case lvl < ErrorLevel:
+		return 4
+	case lvl < FatalLevel:
+		return 3
+	default:
+		return 2
+	}
+}
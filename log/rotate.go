@@ -0,0 +1,325 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingFile is an io.WriteCloser that writes to a file and rotates it
+// when it grows beyond MaxSize bytes or becomes older than MaxAge. Rotated
+// files are renamed with a timestamp suffix and optionally gzipped.
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64
+	MaxAge     time.Duration
+	MaxBackups int
+	Compress   bool
+
+	mu       sync.Mutex
+	millMu   sync.Mutex
+	mills    sync.WaitGroup
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	now      func() time.Time
+}
+
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.file == nil {
+		if err := rf.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if rf.shouldRotate(int64(len(p))) {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file and waits for the compression and pruning
+// of the rotated files to complete.
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	// mills are started with the lock held, so none can be added while
+	// waiting here.
+	defer rf.mills.Wait()
+
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
+
+func (rf *RotatingFile) shouldRotate(incoming int64) bool {
+	if rf.size == 0 {
+		return false
+	}
+	if rf.MaxSize > 0 && rf.size+incoming > rf.MaxSize {
+		return true
+	}
+	return rf.MaxAge > 0 && rf.clock().Sub(rf.openedAt) >= rf.MaxAge
+}
+
+func (rf *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(rf.Path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(rf.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	rf.file = f
+	rf.size = stat.Size()
+	rf.openedAt = rf.clock()
+	if rf.size > 0 {
+		rf.openedAt = rf.createdAt(stat)
+	}
+	return nil
+}
+
+// createdAt returns the time at which the existing file was created so
+// that MaxAge applies across restarts. The file is created by the latest
+// rotation if there are backups, otherwise its modification time is used.
+func (rf *RotatingFile) createdAt(stat os.FileInfo) time.Time {
+	backups, err := rf.backups()
+	if err != nil || len(backups) == 0 {
+		return stat.ModTime()
+	}
+
+	at, _, _ := rf.parseBackup(strings.TrimSuffix(filepath.Base(backups[len(backups)-1]), ".gz"))
+	return at
+}
+
+func (rf *RotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+	rf.file = nil
+
+	backup := rf.backupName()
+	if err := os.Rename(rf.Path, backup); err != nil {
+		return err
+	}
+
+	rf.mills.Add(1)
+	go func() {
+		defer rf.mills.Done()
+		if err := rf.mill(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "log: failed to process rotated file '%s': %v\n", backup, err)
+		}
+	}()
+	return rf.open()
+}
+
+// backupName returns the name for the rotated file. A sequence number is
+// added if a backup with the same timestamp exists (e.g., rotations within
+// a millisecond) so that it is not overwritten.
+func (rf *RotatingFile) backupName() string {
+	ext := filepath.Ext(rf.Path)
+	base := strings.TrimSuffix(rf.Path, ext)
+	stamp := rf.clock().Format(backupTimeFormat)
+
+	name := fmt.Sprintf("%s-%s%s", base, stamp, ext)
+	for seq := 1; fileExists(name) || fileExists(name+".gz"); seq++ {
+		name = fmt.Sprintf("%s-%s-%d%s", base, stamp, seq, ext)
+	}
+	return name
+}
+
+// mill compresses the rotated file (if enabled) and removes the backups
+// exceeding MaxBackups. Mills of consecutive rotations may run out of
+// order, so the backup may have been pruned already by a later mill.
+func (rf *RotatingFile) mill(backup string) error {
+	rf.millMu.Lock()
+	defer rf.millMu.Unlock()
+
+	if rf.Compress {
+		if err := gzipFile(backup); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if rf.MaxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := rf.backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > rf.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// backups returns the rotated files (compressed or not) sorted from the
+// oldest to the newest. Only the names with the suffix added by rotate()
+// are matched so that the other files in the directory (e.g., 'app-access.log'
+// for 'app.log') are not mistaken for backups.
+func (rf *RotatingFile) backups() ([]string, error) {
+	dir := filepath.Dir(rf.Path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type backup struct {
+		path string
+		at   time.Time
+		seq  int
+	}
+
+	var found []backup
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		at, seq, ok := rf.parseBackup(strings.TrimSuffix(e.Name(), ".gz"))
+		if !ok {
+			continue
+		}
+		found = append(found, backup{path: filepath.Join(dir, e.Name()), at: at, seq: seq})
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].at.Equal(found[j].at) {
+			return found[i].at.Before(found[j].at)
+		}
+		return found[i].seq < found[j].seq
+	})
+
+	res := make([]string, len(found))
+	for i, b := range found {
+		res[i] = b.path
+	}
+	return res, nil
+}
+
+// parseBackup returns the rotation time and the sequence number from the
+// base name of a backup created by rotate() (without the '.gz').
+func (rf *RotatingFile) parseBackup(name string) (time.Time, int, bool) {
+	ext := filepath.Ext(rf.Path)
+	prefix := strings.TrimSuffix(filepath.Base(rf.Path), ext) + "-"
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+		return time.Time{}, 0, false
+	}
+
+	mid := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+	if len(mid) < len(backupTimeFormat) {
+		return time.Time{}, 0, false
+	}
+	at, err := time.Parse(backupTimeFormat, mid[:len(backupTimeFormat)])
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+
+	seq := 0
+	if rest := mid[len(backupTimeFormat):]; rest != "" {
+		seq, err = strconv.Atoi(strings.TrimPrefix(rest, "-"))
+		if err != nil || seq <= 0 || rest != "-"+strconv.Itoa(seq) {
+			return time.Time{}, 0, false
+		}
+	}
+	return at, seq, true
+}
+
+func (rf *RotatingFile) clock() time.Time {
+	if rf.now != nil {
+		return rf.now()
+	}
+	return time.Now()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// parseSize parses sizes like "512", "10KB", "100MB" or "1GB".
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		mul    int64
+	}{
+		{"KB", 1 << 10},
+		{"MB", 1 << 20},
+		{"GB", 1 << 30},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			multiplier = unit.mul
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%s'", s)
+	}
+	return n * multiplier, nil
+}
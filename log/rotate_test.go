@@ -0,0 +1,303 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rf := &RotatingFile{
+		Path:       filepath.Join(dir, "app.log"),
+		MaxSize:    10,
+		MaxAge:     time.Hour,
+		MaxBackups: 2,
+		Compress:   true,
+		now:        func() time.Time { return now },
+	}
+	defer rf.Close()
+
+	write := func(s string) {
+		_, err := rf.Write([]byte(s))
+		require.NoError(t, err)
+	}
+
+	write("12345")
+	write("12345") // exactly at max-size, no rotation.
+	write("abc")   // exceeds max-size, rotated.
+	now = now.Add(time.Second)
+	write("defghijk") // exceeds max-size, rotated.
+	now = now.Add(time.Hour)
+	write("x") // exceeds max-age, rotated.
+
+	data, err := os.ReadFile(rf.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(data))
+
+	assert.Eventually(t, func() bool {
+		backups, _ := filepath.Glob(filepath.Join(dir, "app-*.log.gz"))
+		others, _ := filepath.Glob(filepath.Join(dir, "app-*.log"))
+		return len(backups) == 2 && len(others) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRotatingFile_Siblings(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	siblings := []string{"app-access.log", "app-access.log.gz", "app-v2.log", "app-error-2022-01-01.log"}
+	for _, name := range siblings {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("keep"), 0o644))
+	}
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	rf := &RotatingFile{
+		Path:       filepath.Join(dir, "app.log"),
+		MaxSize:    1,
+		MaxBackups: 1,
+		now:        func() time.Time { return now },
+	}
+	defer rf.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err := rf.Write([]byte("x"))
+		require.NoError(t, err)
+		now = now.Add(time.Second)
+	}
+
+	want := []string{filepath.Join(dir, "app-2022-01-01T00-00-02.000.log")}
+	assert.Eventually(t, func() bool {
+		backups, err := rf.backups()
+		return err == nil && assert.ObjectsAreEqual(want, backups)
+	}, time.Second, 10*time.Millisecond)
+	for _, name := range siblings {
+		assert.FileExists(t, filepath.Join(dir, name))
+	}
+}
+
+func TestRotatingFile_MaxAgeAcrossRestarts(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	table := []struct {
+		title      string
+		backup     string
+		modifiedAt time.Time
+		wantRotate bool
+	}{
+		{
+			title:      "FromLatestBackup",
+			backup:     "app-2022-01-01T10-00-00.000.log.gz",
+			modifiedAt: now.Add(-time.Minute),
+			wantRotate: true,
+		},
+		{
+			title:      "FromModTime",
+			modifiedAt: now.Add(-2 * time.Hour),
+			wantRotate: true,
+		},
+		{
+			title:      "NotExpired",
+			backup:     "app-2022-01-01T11-30-00.000.log.gz",
+			modifiedAt: now.Add(-time.Minute),
+		},
+	}
+
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.title, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "app.log")
+			require.NoError(t, os.WriteFile(path, []byte("old"), 0o644))
+			require.NoError(t, os.Chtimes(path, tt.modifiedAt, tt.modifiedAt))
+			if tt.backup != "" {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, tt.backup), nil, 0o644))
+			}
+
+			rf := &RotatingFile{
+				Path:   path,
+				MaxAge: time.Hour,
+				now:    func() time.Time { return now },
+			}
+			_, err := rf.Write([]byte("new"))
+			require.NoError(t, err)
+			require.NoError(t, rf.Close())
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			if tt.wantRotate {
+				assert.Equal(t, "new", string(data))
+			} else {
+				assert.Equal(t, "oldnew", string(data))
+			}
+		})
+	}
+}
+
+func TestRotatingFile_SameTimestamp(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	rf := &RotatingFile{
+		Path:    filepath.Join(dir, "app.log"),
+		MaxSize: 1,
+		now:     func() time.Time { return now },
+	}
+	defer rf.Close()
+
+	for _, s := range []string{"1", "2", "3", "4"} {
+		_, err := rf.Write([]byte(s))
+		require.NoError(t, err)
+	}
+
+	backups, err := rf.backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 3, "backups with the same timestamp must not be overwritten")
+
+	var got []string
+	for _, b := range backups {
+		data, err := os.ReadFile(b)
+		require.NoError(t, err)
+		got = append(got, string(data))
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, got)
+}
+
+func TestRotatingFile_CloseWaitsForMills(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	rf := &RotatingFile{
+		Path:       filepath.Join(dir, "app.log"),
+		MaxSize:    1 << 20,
+		MaxBackups: 3,
+		Compress:   true,
+	}
+
+	chunk := bytes.Repeat([]byte("a log line that compresses well\n"), (1<<20)/32)
+	for i := 0; i < 6; i++ {
+		_, err := rf.Write(chunk)
+		require.NoError(t, err)
+	}
+	require.NoError(t, rf.Close())
+
+	backups, err := rf.backups()
+	require.NoError(t, err)
+	assert.Len(t, backups, 3)
+	for _, b := range backups {
+		require.True(t, strings.HasSuffix(b, ".gz"), "backup '%s' must be compressed before Close returns", b)
+
+		f, err := os.Open(b)
+		require.NoError(t, err)
+		gz, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		_, err = io.Copy(io.Discard, gz)
+		assert.NoError(t, err)
+		_ = f.Close()
+	}
+}
+
+func TestRotatingFile_MillOutOfOrder(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	rf := &RotatingFile{
+		Path:       filepath.Join(dir, "app.log"),
+		MaxBackups: 1,
+		Compress:   true,
+	}
+
+	older := filepath.Join(dir, "app-2022-01-01T00-00-00.000.log")
+	newer := filepath.Join(dir, "app-2022-01-01T00-00-01.000.log")
+	require.NoError(t, os.WriteFile(older, []byte("1"), 0o644))
+	require.NoError(t, os.WriteFile(newer, []byte("2"), 0o644))
+
+	// mill goroutines of consecutive rotations can acquire the lock in
+	// any order. Newer one prunes the uncompressed older backup before
+	// the older one gets to compress it.
+	require.NoError(t, rf.mill(newer))
+	assert.NoFileExists(t, older)
+	assert.NoError(t, rf.mill(older))
+
+	backups, err := rf.backups()
+	require.NoError(t, err)
+	assert.Equal(t, []string{newer + ".gz"}, backups)
+}
+
+func Test_parseOutput(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		title     string
+		spec      string
+		wantErr   bool
+		wantLevel *Level
+		wantFile  *RotatingFile
+	}{
+		{
+			title: "Stderr",
+			spec:  "stderr",
+		},
+		{
+			title:     "StdoutWithLevel",
+			spec:      "stdout?level=warn&format=json",
+			wantLevel: func() *Level { l := WarnLevel; return &l }(),
+		},
+		{
+			title: "FileURL",
+			spec:  "file:///var/log/app.log?max_size=10MB&max_age=24h&max_backups=3&compress=true",
+			wantFile: &RotatingFile{
+				Path:       "/var/log/app.log",
+				MaxSize:    10 << 20,
+				MaxAge:     24 * time.Hour,
+				MaxBackups: 3,
+				Compress:   true,
+			},
+		},
+		{
+			title:    "PlainPath",
+			spec:     "logs/app.log",
+			wantFile: &RotatingFile{Path: "logs/app.log"},
+		},
+		{
+			title:   "InvalidSize",
+			spec:    "file:///app.log?max_size=big",
+			wantErr: true,
+		},
+		{
+			title:   "UnknownScheme",
+			spec:    "kafka://localhost:9092",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.title, func(t *testing.T) {
+			got, err := parseOutput(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantLevel, got.level)
+			if tt.wantFile != nil {
+				assert.Equal(t, tt.wantFile, got.writer)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SamplingOptions controls sampling of log entries. Entries are grouped
+// by level and message template (i.e., the format string or the message
+// passed to the logging function).
+type SamplingOptions struct {
+	// First is the number of entries per template logged in each Tick.
+	First int
+
+	// Thereafter sets that every Thereafter-th entry after First in the
+	// same Tick is logged. Zero value drops all entries after First.
+	Thereafter int
+
+	// Tick is the sampling window. Defaults to 1 second.
+	Tick time.Duration
+
+	// SummaryInterval is the interval at which a summary entry reporting
+	// the dropped counts is written (only if entries were dropped since
+	// the last one). Defaults to 1 minute.
+	SummaryInterval time.Duration
+}
+
+// WithSampling enables sampling of the log entries. Fatal entries are
+// never sampled.
+func WithSampling(opts SamplingOptions) Option {
+	return func(o *options) { o.sampling = &opts }
+}
+
+// NewSampler returns a Handler that samples the entries before passing
+// them to h. See SamplingOptions for details. The returned handler also
+// implements io.Closer to stop the summary goroutine (pending summary is
+// written on Close). Setup() closes it when the handler is replaced.
+func NewSampler(h Handler, opts SamplingOptions) Handler {
+	return newSampler(h, opts, time.Now)
+}
+
+func newSampler(h Handler, opts SamplingOptions, now func() time.Time) *sampler {
+	if opts.Tick <= 0 {
+		opts.Tick = time.Second
+	}
+	if opts.SummaryInterval <= 0 {
+		opts.SummaryInterval = time.Minute
+	}
+	s := &sampler{
+		next:     h,
+		opts:     opts,
+		now:      now,
+		counters: map[sampleKey]*sampleCounter{},
+		dropped:  map[string]int64{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.summaryLoop()
+	return s
+}
+
+type sampleKey struct {
+	level    Level
+	template string
+}
+
+type sampleCounter struct {
+	windowStart time.Time
+	count       int
+}
+
+type sampler struct {
+	next Handler
+	opts SamplingOptions
+	now  func() time.Time
+
+	mu        sync.Mutex
+	counters  map[sampleKey]*sampleCounter
+	dropped   map[string]int64
+	lastEvict time.Time
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func (s *sampler) Enabled(ctx context.Context, lvl Level) bool {
+	return s.next.Enabled(ctx, lvl)
+}
+
+func (s *sampler) Handle(ctx context.Context, e Entry) error {
+	if !s.sample(e) {
+		return nil
+	}
+	return s.next.Handle(ctx, e)
+}
+
+// Close stops the summary goroutine and writes the pending summary.
+func (s *sampler) Close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+	return nil
+}
+
+func (s *sampler) summaryLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.opts.SummaryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			s.writeSummary()
+			return
+
+		case <-ticker.C:
+			s.writeSummary()
+		}
+	}
+}
+
+func (s *sampler) writeSummary() {
+	s.mu.Lock()
+	summary := s.summary(s.now())
+	s.mu.Unlock()
+
+	// summary does not go through the package functions, so the level is
+	// checked here.
+	ctx := context.Background()
+	if summary != nil && WarnLevel >= GetLevel() && s.next.Enabled(ctx, WarnLevel) {
+		_ = s.next.Handle(ctx, *summary)
+	}
+}
+
+func (s *sampler) sample(e Entry) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.evict(now)
+
+	keep := true
+	if e.Level < FatalLevel {
+		key := sampleKey{level: e.Level, template: e.Template}
+		counter, found := s.counters[key]
+		if !found || now.Sub(counter.windowStart) >= s.opts.Tick {
+			counter = &sampleCounter{windowStart: now}
+			s.counters[key] = counter
+		}
+		counter.count++
+
+		if over := counter.count - s.opts.First; over > 0 {
+			keep = s.opts.Thereafter > 0 && over%s.opts.Thereafter == 0
+		}
+		if !keep {
+			s.dropped[e.Template]++
+		}
+	}
+
+	return keep
+}
+
+// evict removes the counters of the expired windows (at most once per
+// Tick) so that the templates seen once do not accumulate.
+func (s *sampler) evict(now time.Time) {
+	if now.Sub(s.lastEvict) < s.opts.Tick {
+		return
+	}
+	s.lastEvict = now
+
+	for key, counter := range s.counters {
+		if now.Sub(counter.windowStart) >= s.opts.Tick {
+			delete(s.counters, key)
+		}
+	}
+}
+
+func (s *sampler) summary(now time.Time) *Entry {
+	if len(s.dropped) == 0 {
+		return nil
+	}
+
+	var total int64
+	dropped := make(map[string]int64, len(s.dropped))
+	for tpl, n := range s.dropped {
+		dropped[tpl] = n
+		total += n
+	}
+
+	s.dropped = map[string]int64{}
+
+	return &Entry{
+		Time:     now,
+		Level:    WarnLevel,
+		Message:  fmt.Sprintf("dropped %d log entries due to sampling", total),
+		Template: "dropped %d log entries due to sampling",
+		Fields:   Fields{"dropped": dropped},
+	}
+}
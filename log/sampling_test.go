@@ -0,0 +1,128 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampler(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	rh := &recordingHandler{}
+	s := newSampler(rh, SamplingOptions{
+		First:           2,
+		Thereafter:      3,
+		Tick:            time.Second,
+		SummaryInterval: 10 * time.Second,
+	}, func() time.Time { return now })
+	defer s.Close()
+
+	logN := func(n int, lvl Level, tpl string) {
+		for i := 0; i < n; i++ {
+			_ = s.Handle(context.Background(), Entry{Level: lvl, Message: tpl, Template: tpl})
+		}
+	}
+	messages := func() []string {
+		var res []string
+		for _, e := range rh.entries {
+			res = append(res, e.Message)
+		}
+		rh.entries = nil
+		return res
+	}
+
+	// first 2 are logged, then every 3rd one (5th, 8th).
+	logN(8, ErrorLevel, "failed: %v")
+	logN(1, InfoLevel, "failed: %v")
+	logN(2, FatalLevel, "fatal")
+	assert.Equal(t, []string{
+		"failed: %v", "failed: %v", "failed: %v", "failed: %v",
+		"failed: %v", "fatal", "fatal",
+	}, messages())
+
+	// new window starts after tick.
+	now = now.Add(time.Second)
+	logN(3, ErrorLevel, "failed: %v")
+	assert.Equal(t, []string{"failed: %v", "failed: %v"}, messages())
+
+	// summary is written by the summary loop.
+	now = now.Add(10 * time.Second)
+	s.writeSummary()
+	got := rh.entries
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, WarnLevel, got[0].Level)
+		assert.Equal(t, "dropped 5 log entries due to sampling", got[0].Message)
+		assert.Equal(t, map[string]int64{"failed: %v": 5}, got[0].Fields["dropped"])
+	}
+	rh.entries = nil
+
+	// no summary when nothing is dropped.
+	now = now.Add(time.Minute)
+	s.writeSummary()
+	logN(1, InfoLevel, "hello")
+	assert.Equal(t, []string{"hello"}, messages())
+
+	// expired windows are evicted.
+	for i := 0; i < 100; i++ {
+		logN(1, InfoLevel, fmt.Sprintf("unique %d", i))
+	}
+	now = now.Add(time.Second)
+	logN(1, InfoLevel, "hello")
+	assert.Len(t, s.counters, 1)
+}
+
+func TestSampler_SummaryInterval(t *testing.T) {
+	t.Parallel()
+
+	summaries := make(chan Entry, 1)
+	s := NewSampler(handlerFunc(func(e Entry) {
+		if e.Level == WarnLevel {
+			summaries <- e
+		}
+	}), SamplingOptions{First: 1, SummaryInterval: 50 * time.Millisecond})
+	defer s.(io.Closer).Close()
+
+	for i := 0; i < 3; i++ {
+		_ = s.Handle(context.Background(), Entry{Level: InfoLevel, Message: "hello", Template: "hello"})
+	}
+
+	// written without any further entries.
+	select {
+	case e := <-summaries:
+		assert.Equal(t, "dropped 2 log entries due to sampling", e.Message)
+	case <-time.After(2 * time.Second):
+		t.Fatal("summary was not written")
+	}
+}
+
+func TestSampler_SummaryLevel(t *testing.T) {
+	SetLevel(ErrorLevel)
+	defer SetLevel(InfoLevel)
+
+	rh := &recordingHandler{}
+	s := newSampler(rh, SamplingOptions{First: 1}, time.Now)
+	for i := 0; i < 3; i++ {
+		_ = s.Handle(context.Background(), Entry{Level: ErrorLevel, Message: "failed", Template: "failed"})
+	}
+	assert.NoError(t, s.Close())
+
+	for _, e := range rh.entries {
+		assert.NotEqual(t, WarnLevel, e.Level, "summary must not be written when warn is disabled")
+	}
+	assert.Len(t, rh.entries, 1)
+}
+
+type handlerFunc func(e Entry)
+
+func (fn handlerFunc) Enabled(context.Context, Level) bool { return true }
+
+func (fn handlerFunc) Handle(_ context.Context, e Entry) error {
+	fn(e)
+	return nil
+}
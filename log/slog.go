@@ -0,0 +1,100 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Slog returns a *slog.Logger that writes through the log package. The
+// level, handler and context fields configured for the log package apply
+// to the entries logged through it.
+func Slog() *slog.Logger {
+	return slog.New(&slogAdapter{})
+}
+
+// SlogHandler returns the slog.Handler used by the logger returned from
+// Slog(). Use this when a library needs a handler instead of a logger.
+func SlogHandler() slog.Handler { return &slogAdapter{} }
+
+type slogAdapter struct {
+	attrs  []slog.Attr
+	groups []string
+}
+
+func (sa *slogAdapter) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return Enabled(ctx, Level(lvl))
+}
+
+func (sa *slogAdapter) Handle(ctx context.Context, rec slog.Record) error {
+	fields := make(Fields, len(sa.attrs)+rec.NumAttrs())
+	for _, a := range sa.attrs {
+		addAttr(fields, "", a)
+	}
+
+	prefix := groupPrefix(sa.groups)
+	rec.Attrs(func(a slog.Attr) bool {
+		addAttr(fields, prefix, a)
+		return true
+	})
+
+	e := Entry{
+		Time:     rec.Time,
+		Level:    Level(rec.Level),
+		Message:  rec.Message,
+		Template: rec.Message,
+		Fields:   mergeFields(fromCtx(ctx), fields),
+	}
+	return handlerFor(ctx).Handle(ctx, e)
+}
+
+func (sa *slogAdapter) WithAttrs(attrs []slog.Attr) slog.Handler {
+	prefix := groupPrefix(sa.groups)
+
+	cloned := &slogAdapter{groups: sa.groups}
+	cloned.attrs = append(cloned.attrs, sa.attrs...)
+	for _, a := range attrs {
+		if prefix != "" {
+			a.Key = prefix + a.Key
+		}
+		cloned.attrs = append(cloned.attrs, a)
+	}
+	return cloned
+}
+
+func (sa *slogAdapter) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return sa
+	}
+	cloned := &slogAdapter{attrs: sa.attrs}
+	cloned.groups = append(append([]string{}, sa.groups...), name)
+	return cloned
+}
+
+// addAttr flattens the attribute into fields. Groups are flattened into
+// dot-separated keys.
+func addAttr(fields Fields, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix = prefix + a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			addAttr(fields, groupPrefix, ga)
+		}
+		return
+	}
+	fields[prefix+a.Key] = a.Value.Any()
+}
+
+func groupPrefix(groups []string) string {
+	var prefix string
+	for _, g := range groups {
+		prefix += g + "."
+	}
+	return prefix
+}
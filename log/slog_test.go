@@ -0,0 +1,46 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingHandler struct {
+	entries []Entry
+}
+
+func (rh *recordingHandler) Enabled(context.Context, Level) bool { return true }
+
+func (rh *recordingHandler) Handle(_ context.Context, e Entry) error {
+	rh.entries = append(rh.entries, e)
+	return nil
+}
+
+func TestSlog(t *testing.T) {
+	rh := &recordingHandler{}
+	SetHandler(rh)
+	SetLevel(InfoLevel)
+	defer SetHandler(newBackend("slog", "text", os.Stderr))
+
+	ctx := With(context.Background(), "request_id", "abc")
+	lg := Slog().With("component", "db").WithGroup("query")
+
+	lg.DebugContext(ctx, "not logged")
+	lg.InfoContext(ctx, "executed", "rows", 10, slog.Group("timing", "ms", 5))
+
+	if assert.Len(t, rh.entries, 1) {
+		e := rh.entries[0]
+		assert.Equal(t, InfoLevel, e.Level)
+		assert.Equal(t, "executed", e.Message)
+		assert.Equal(t, Fields{
+			"request_id":      "abc",
+			"component":       "db",
+			"query.rows":      int64(10),
+			"query.timing.ms": int64(5),
+		}, e.Fields)
+	}
+}
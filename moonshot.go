@@ -4,10 +4,17 @@ import (
 	"context"
 	"fmt"
 	"io/fs"
+	"net/http"
+	"os"
+	"strings"
 
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/go-chi/chi"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
 
+	"github.com/spy16/moonshot/httputils"
 	"github.com/spy16/moonshot/log"
 )
 
@@ -17,9 +24,45 @@ type App struct {
 	Name     string
 	Short    string
 	Long     string
+	Version  string
 	CfgPtr   interface{}
 	Routes   func(r *chi.Mux) error
 	StaticFS fs.FS
+
+	// Server holds the HTTP server configs. Point this to a ServerConfig
+	// embedded in the config struct to load it from config files/env.
+	// Defaults are applied to the empty values before loading the configs,
+	// so explicitly empty values in the configs (e.g., 'middlewares: []')
+	// are kept.
+	Server *ServerConfig
+
+	// Middlewares are applied to all the routes after the built-in
+	// middlewares enabled in ServerConfig (or at the position of 'app'
+	// in ServerConfig.Middlewares).
+	Middlewares []func(next http.Handler) http.Handler
+
+	// PanicReporter is invoked when a handler panics if the 'recover'
+	// middleware is enabled. Use this to report panics to external trackers.
+	PanicReporter httputils.PanicReporter
+
+	// GraphQL is the executable schema (generated by gqlgen) served at the
+	// route configured in ServerConfig.GraphQL.
+	GraphQL graphql.ExecutableSchema
+
+	// GraphQLInit is invoked with the init payload of the subscription
+	// websockets (e.g., to authenticate using a token in the payload).
+	// Returned context is used for the subscriptions on the connection.
+	GraphQLInit transport.WebsocketInitFunc
+
+	// GRPC registers the gRPC services. When set, the services are served
+	// on the '--grpc-addr' if given or on the HTTP address otherwise.
+	GRPC func(srv *grpc.Server) error
+
+	// AdminGuard is applied to the admin endpoints mounted under '/_'
+	// (e.g., PUT /_/loglevel). Defaults to allowing only the requests made
+	// directly from the loopback interface (not over Unix sockets and not
+	// forwarded by a proxy).
+	AdminGuard func(next http.Handler) http.Handler
 }
 
 func (app *App) Launch(ctx context.Context, cmds ...*cobra.Command) int {
@@ -34,13 +77,31 @@ func (app *App) Launch(ctx context.Context, cmds ...*cobra.Command) int {
 
 	flags := root.PersistentFlags()
 
-	var logLevel, logFormat string
+	var logLevel, logFormat, logBackend, logRedact string
+	var logOutputs []string
 	flags.StringP("config", "c", "", "Config file path override")
 	flags.StringVar(&logLevel, "log-level", "info", "Log level")
 	flags.StringVar(&logFormat, "log-format", "text", "Log format (json/text)")
+	flags.StringVar(&logBackend, "log-backend", "slog", "Log backend (slog/logrus)")
+	flags.StringSliceVar(&logOutputs, "log-output", []string{"stderr"}, "Log outputs (stderr, stdout, file path/URL, syslog://, journald://)")
+	flags.StringVar(&logRedact, "log-redact", "keys", "Redaction of sensitive values in logs (keys/all/none)")
 
 	root.PersistentPreRun = func(cmd *cobra.Command, args []string) {
-		log.Setup(logLevel, logFormat)
+		logOpts := []log.Option{log.WithBackend(logBackend), log.WithOutputs(logOutputs...)}
+		redactOpt, err := redactOption(logRedact)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to setup logger: %v\n", err)
+			os.Exit(1)
+		}
+		if redactOpt != nil {
+			logOpts = append(logOpts, redactOpt)
+		}
+
+		if err := log.Configure(logLevel, logFormat, logOpts...); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to setup logger: %v\n", err)
+			os.Exit(1)
+		}
+
 		if err := app.loadConfigs(cmd); err != nil {
 			log.Fatalf(ctx, "failed to load configs: %v", err)
 		}
@@ -50,6 +111,7 @@ func (app *App) Launch(ctx context.Context, cmds ...*cobra.Command) int {
 	root.AddCommand(
 		app.cmdServe(ctx),
 		app.cmdShowConfigs(ctx),
+		app.cmdOpenAPI(ctx),
 	)
 
 	if err := root.Execute(); err != nil {
@@ -57,3 +119,24 @@ func (app *App) Launch(ctx context.Context, cmds ...*cobra.Command) int {
 	}
 	return 0
 }
+
+// redactOption returns the log redaction option for the mode: 'keys'
+// masks the values of the sensitive keys, 'all' also the values found by
+// the detectors (JWTs, card numbers etc.) and 'none' disables it.
+func redactOption(mode string) (log.Option, error) {
+	opts := log.DefaultRedactOptions()
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "keys":
+		return log.WithRedaction(opts), nil
+
+	case "all":
+		opts.Detectors = log.DefaultDetectors()
+		return log.WithRedaction(opts), nil
+
+	case "none":
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("invalid log redaction mode '%s'", mode)
+	}
+}
@@ -0,0 +1,140 @@
+package moonshot
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/httputils"
+	"github.com/spy16/moonshot/log"
+)
+
+const adminRoute = "/_"
+
+func (app *App) mountAdmin(router chi.Router) {
+	guard := app.AdminGuard
+	if guard == nil {
+		guard = loopbackOnly
+	}
+
+	router.Route(adminRoute, func(r chi.Router) {
+		r.Use(guard)
+		r.Get("/loglevel", getLogLevelHandler())
+		r.Put("/loglevel", putLogLevelHandler())
+		r.Delete("/loglevel", deleteLogLevelHandler())
+	})
+}
+
+type logLevelReq struct {
+	Level    string `json:"level"`
+	Logger   string `json:"logger"`
+	Duration string `json:"duration"`
+}
+
+type logLevelResp struct {
+	Level   string            `json:"level"`
+	Loggers map[string]string `json:"loggers"`
+}
+
+func getLogLevelHandler() http.HandlerFunc {
+	return func(wr http.ResponseWriter, req *http.Request) {
+		httputils.Respond(wr, req, http.StatusOK, currentLogLevels())
+	}
+}
+
+func putLogLevelHandler() http.HandlerFunc {
+	return func(wr http.ResponseWriter, req *http.Request) {
+		var body logLevelReq
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			httputils.Respond(wr, req, http.StatusBadRequest,
+				errors.ErrInvalid.WithMsgf("invalid json body").WithCausef(err.Error()))
+			return
+		}
+
+		lvl, err := log.ParseLevel(body.Level)
+		if err != nil {
+			httputils.Respond(wr, req, http.StatusBadRequest, errors.ErrInvalid.WithMsgf(err.Error()))
+			return
+		}
+
+		if body.Duration == "" {
+			log.SetLoggerLevel(body.Logger, lvl)
+		} else {
+			dur, err := time.ParseDuration(body.Duration)
+			if err != nil || dur <= 0 {
+				httputils.Respond(wr, req, http.StatusBadRequest,
+					errors.ErrInvalid.WithMsgf("duration must be a positive duration (e.g., '5m')"))
+				return
+			}
+			log.SetLevelFor(body.Logger, lvl, dur)
+		}
+
+		log.Warn(req.Context(), "log level changed",
+			"logger", body.Logger, "level", lvl.String(), "duration", body.Duration)
+		httputils.Respond(wr, req, http.StatusOK, currentLogLevels())
+	}
+}
+
+func deleteLogLevelHandler() http.HandlerFunc {
+	return func(wr http.ResponseWriter, req *http.Request) {
+		name := req.URL.Query().Get("logger")
+		if name == "" {
+			httputils.Respond(wr, req, http.StatusBadRequest,
+				errors.ErrInvalid.WithMsgf("logger query param is required"))
+			return
+		}
+
+		log.ResetLoggerLevel(name)
+		httputils.Respond(wr, req, http.StatusOK, currentLogLevels())
+	}
+}
+
+func currentLogLevels() logLevelResp {
+	resp := logLevelResp{
+		Level:   log.GetLevel().String(),
+		Loggers: map[string]string{},
+	}
+	for name, lvl := range log.LoggerLevels() {
+		resp.Loggers[name] = lvl.String()
+	}
+	return resp
+}
+
+// loopbackOnly is the default admin guard that allows only the requests
+// made directly from the loopback interface (see httputils.IsLocalPeer).
+// Requests over Unix sockets or through a reverse proxy are rejected, set
+// App.AdminGuard to allow them.
+func loopbackOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if !httputils.IsLocalPeer(req) {
+			httputils.Respond(wr, req, http.StatusForbidden,
+				errors.ErrForbidden.WithMsgf("admin endpoints are accessible only from localhost"))
+			return
+		}
+		next.ServeHTTP(wr, req)
+	})
+}
+
+var levelSteps = []log.Level{log.DebugLevel, log.InfoLevel, log.WarnLevel, log.ErrorLevel}
+
+// stepLevel moves the level by 'step' positions in levelSteps. Negative
+// step makes the logs more verbose.
+func stepLevel(cur log.Level, step int) log.Level {
+	idx := 0
+	for i, lvl := range levelSteps {
+		if cur >= lvl {
+			idx = i
+		}
+	}
+
+	idx += step
+	if idx < 0 {
+		idx = 0
+	} else if idx >= len(levelSteps) {
+		idx = len(levelSteps) - 1
+	}
+	return levelSteps[idx]
+}
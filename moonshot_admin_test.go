@@ -0,0 +1,162 @@
+package moonshot
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/httputils"
+	"github.com/spy16/moonshot/log"
+)
+
+func TestAdminGuard(t *testing.T) {
+	table := []struct {
+		title      string
+		remoteAddr string
+		realIP     string
+		forwarded  string
+		unix       bool
+		wantStatus int
+	}{
+		{title: "Loopback", remoteAddr: "127.0.0.1:4242", wantStatus: http.StatusOK},
+		{title: "Remote", remoteAddr: "203.0.113.9:4242", wantStatus: http.StatusForbidden},
+		{title: "RemoteSpoofed", remoteAddr: "203.0.113.9:4242", realIP: "127.0.0.1", wantStatus: http.StatusForbidden},
+		{title: "ProxySpoofed", remoteAddr: "10.0.0.2:4242", realIP: "127.0.0.1", wantStatus: http.StatusForbidden},
+		{title: "LoopbackProxy", remoteAddr: "127.0.0.1:4242", forwarded: "203.0.113.9", wantStatus: http.StatusForbidden},
+		{title: "UnixSocket", remoteAddr: "@", unix: true, wantStatus: http.StatusForbidden},
+	}
+
+	app := &App{Name: "test", Server: &ServerConfig{
+		Middlewares:    []string{MiddlewareRealIP},
+		TrustedProxies: []string{"10.0.0.0/8"},
+	}}
+	router, err := app.buildRouter(app.serverConfig())
+	require.NoError(t, err)
+
+	for _, tt := range table {
+		t.Run(tt.title, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/_/loglevel", strings.NewReader(`{"level":"info"}`))
+			req.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.unix {
+				req = req.WithContext(httputils.WithPeerAddr(req.Context(), &net.UnixAddr{Net: "unix"}))
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestServerConfig_RealIP(t *testing.T) {
+	app := &App{Server: &ServerConfig{Middlewares: []string{MiddlewareRealIP}}}
+	_, err := app.middlewares(app.serverConfig())
+	assert.Error(t, err, "trusted_proxies must be required")
+
+	app.Server.TrustedProxies = []string{"not-an-ip"}
+	_, err = app.middlewares(app.serverConfig())
+	assert.Error(t, err)
+
+	app.Server.TrustedProxies = []string{"10.0.0.1", "fd00::/8"}
+	_, err = app.middlewares(app.serverConfig())
+	assert.NoError(t, err)
+}
+
+func TestLogLevelHandlers(t *testing.T) {
+	log.SetLevel(log.InfoLevel)
+	t.Cleanup(func() {
+		log.SetLevel(log.InfoLevel)
+		log.ResetLoggerLevel("store")
+	})
+
+	app := &App{Name: "test"}
+	router, err := app.buildRouter(app.serverConfig())
+	require.NoError(t, err)
+
+	do := func(method, target, body string) (int, logLevelResp) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.RemoteAddr = "127.0.0.1:4242"
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		var resp logLevelResp
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		}
+		return rec.Code, resp
+	}
+
+	t.Run("InvalidLevel", func(t *testing.T) {
+		code, _ := do(http.MethodPut, "/_/loglevel", `{"level":"verbose"}`)
+		assert.Equal(t, http.StatusBadRequest, code)
+
+		code, _ = do(http.MethodPut, "/_/loglevel", `{"level":"debug","duration":"-5m"}`)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, log.InfoLevel, log.GetLevel())
+	})
+
+	t.Run("SetWithDuration", func(t *testing.T) {
+		code, resp := do(http.MethodPut, "/_/loglevel", `{"level":"debug","logger":"store","duration":"50ms"}`)
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, map[string]string{"store": "debug"}, resp.Loggers)
+		assert.Equal(t, log.DebugLevel, log.LevelOf("store.sql"))
+
+		assert.Eventually(t, func() bool {
+			_, resp := do(http.MethodGet, "/_/loglevel", "")
+			return len(resp.Loggers) == 0 && resp.Level == "info"
+		}, time.Second, 10*time.Millisecond, "level must revert after the duration")
+		assert.Equal(t, log.InfoLevel, log.LevelOf("store.sql"))
+	})
+
+	t.Run("DeleteRestoresDefault", func(t *testing.T) {
+		code, _ := do(http.MethodPut, "/_/loglevel", `{"level":"error","logger":"store"}`)
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, log.ErrorLevel, log.LevelOf("store"))
+
+		code, _ = do(http.MethodDelete, "/_/loglevel", "")
+		assert.Equal(t, http.StatusBadRequest, code, "logger is required")
+
+		code, resp := do(http.MethodDelete, "/_/loglevel?logger=store", "")
+		require.Equal(t, http.StatusOK, code)
+		assert.Empty(t, resp.Loggers)
+		assert.Equal(t, log.InfoLevel, log.LevelOf("store"))
+	})
+}
+
+func TestStepLevel(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		title string
+		cur   log.Level
+		step  int
+		want  log.Level
+	}{
+		{title: "MoreVerbose", cur: log.InfoLevel, step: -1, want: log.DebugLevel},
+		{title: "LessVerbose", cur: log.InfoLevel, step: 1, want: log.WarnLevel},
+		{title: "BelowDebug", cur: log.DebugLevel, step: -1, want: log.DebugLevel},
+		{title: "AboveError", cur: log.ErrorLevel, step: 1, want: log.ErrorLevel},
+		{title: "FatalStepsDown", cur: log.FatalLevel, step: -1, want: log.WarnLevel},
+		{title: "LargeStep", cur: log.DebugLevel, step: 10, want: log.ErrorLevel},
+	}
+
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.title, func(t *testing.T) {
+			assert.Equal(t, tt.want, stepLevel(tt.cur, tt.step))
+		})
+	}
+}
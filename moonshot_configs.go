@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/mcuadros/go-defaults"
 	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
@@ -60,6 +61,12 @@ func (app *App) loadConfigs(cmd *cobra.Command) error {
 		opts = append(opts, config.WithFile(cfgFile))
 	}
 
+	// defaults are applied before loading so that the empty values in the
+	// configs are not replaced by them.
+	if app.Server != nil {
+		defaults.SetDefaults(app.Server)
+	}
+
 	if err := config.Load(app.CfgPtr, opts...); err != nil {
 		return err
 	}
@@ -0,0 +1,52 @@
+package moonshot
+
+import (
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/go-chi/chi"
+
+	"github.com/spy16/moonshot/gql"
+	"github.com/spy16/moonshot/httputils"
+)
+
+// GraphQLConfig holds the configurations for the GraphQL endpoint mounted
+// when App.GraphQL is set. Refer gql.Options for details.
+type GraphQLConfig struct {
+	Route string `mapstructure:"route" default:"/graphql"`
+
+	// Playground is the route of the GraphQL playground. Set
+	// DisablePlayground to turn it off (e.g., in production).
+	Playground        string `mapstructure:"playground" default:"/graphql/playground"`
+	DisablePlayground bool   `mapstructure:"disable_playground"`
+
+	// ComplexityLimit and PersistedQueries (cache size) can be set to a
+	// negative value to disable.
+	ComplexityLimit  int `mapstructure:"complexity_limit" default:"1000"`
+	PersistedQueries int `mapstructure:"persisted_queries" default:"1000"`
+
+	DisableIntrospection bool          `mapstructure:"disable_introspection"`
+	KeepAlive            time.Duration `mapstructure:"keep_alive" default:"10s"`
+
+	// AllowedOrigins are the origins (e.g., 'https://*.example.com')
+	// allowed to open subscription websockets in addition to the same
+	// origin. Use '*' to allow all.
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+}
+
+func (app *App) mountGraphQL(router *chi.Mux, cfg GraphQLConfig) {
+	h := gql.Handler(app.GraphQL, gql.Options{
+		ComplexityLimit:      cfg.ComplexityLimit,
+		PersistedQueries:     cfg.PersistedQueries,
+		DisableIntrospection: cfg.DisableIntrospection,
+		KeepAlive:            cfg.KeepAlive,
+		CheckOrigin:          httputils.CheckOrigin(cfg.AllowedOrigins),
+		InitFunc:             app.GraphQLInit,
+		PanicReporter:        app.PanicReporter,
+	})
+	router.Handle(cfg.Route, h)
+
+	if !cfg.DisablePlayground {
+		router.Handle(cfg.Playground, playground.Handler(app.Name+" - GraphQL", cfg.Route))
+	}
+}
@@ -0,0 +1,35 @@
+package moonshot
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/spy16/moonshot/grpcutils"
+)
+
+// GRPCConfig holds the configurations for the gRPC server started when
+// App.GRPC is set. Refer grpcutils.Options for details.
+type GRPCConfig struct {
+	DisableReflection bool `mapstructure:"disable_reflection"`
+}
+
+// newGRPCServer returns the gRPC server with the app services registered.
+// TLS is enabled if tlsCfg is not nil.
+func (app *App) newGRPCServer(cfg GRPCConfig, tlsCfg *tls.Config) (*grpcutils.Server, error) {
+	opts := grpcutils.Options{
+		PanicReporter:     app.PanicReporter,
+		DisableReflection: cfg.DisableReflection,
+	}
+	if tlsCfg != nil {
+		opts.ServerOptions = append(opts.ServerOptions, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+
+	srv := grpcutils.NewServer(opts)
+	if err := app.GRPC(srv.Server); err != nil {
+		return nil, fmt.Errorf("grpc service setup failed: %w", err)
+	}
+	return srv, nil
+}
@@ -0,0 +1,305 @@
+package moonshot
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/mcuadros/go-defaults"
+
+	"github.com/spy16/moonshot/httputils"
+)
+
+// Names of the built-in middlewares that can be used in the middleware
+// list of ServerConfig.
+const (
+	MiddlewareRecover   = "recover"
+	MiddlewareRequestID = "request_id"
+	MiddlewareRealIP    = "real_ip"
+	MiddlewareAccessLog = "access_log"
+	MiddlewareTimeout   = "timeout"
+	MiddlewareCompress  = "compress"
+	MiddlewareCORS      = "cors"
+	MiddlewareBodyLimit = "body_limit"
+	MiddlewareRateLimit = "rate_limit"
+
+	// MiddlewareApp marks the position of App.Middlewares in the list.
+	// App.Middlewares are applied after the built-ins if it is omitted.
+	MiddlewareApp = "app"
+)
+
+// ServerConfig holds the configurations for the HTTP server started by
+// the serve command. Embed this in the config struct and set App.Server
+// to point to it to load it along with the app configs.
+type ServerConfig struct {
+	// Middlewares is the ordered list of built-in middlewares to enable.
+	// 'recover' should be placed after 'access_log' so that the recovered
+	// panics are logged with the correct status. 'rate_limit' with 'user'
+	// key must be placed after 'app' (i.e., the App.Middlewares) since
+	// the identity is set by the auth middlewares.
+	Middlewares []string `mapstructure:"middlewares" default:"[request_id,access_log,compress,recover]"`
+
+	// TrustedProxies are the IPs/CIDRs (e.g., '10.0.0.0/8') of the proxies
+	// allowed to set the client IP via 'X-Forwarded-For' and 'X-Real-IP'.
+	// Required when the 'real_ip' middleware is enabled.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+
+	// DisableDocs disables the '/openapi.json' and '/docs' endpoints.
+	DisableDocs bool `mapstructure:"disable_docs"`
+
+	AccessLog AccessLogConfig `mapstructure:"access_log"`
+	CORS      CORSConfig      `mapstructure:"cors"`
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+	Compress  CompressConfig  `mapstructure:"compress"`
+	GraphQL   GraphQLConfig   `mapstructure:"graphql"`
+	GRPC      GRPCConfig      `mapstructure:"grpc"`
+	TLS       TLSConfig       `mapstructure:"tls"`
+
+	// Timeout is the deadline for the handlers when the 'timeout'
+	// middleware is enabled. Use httputils.Timeout() with chi's With()
+	// for route specific timeouts.
+	Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+
+	// ReadHeaderTimeout, ReadTimeout, WriteTimeout and IdleTimeout are
+	// set on the http.Server. Set a negative value to disable.
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout" default:"10s"`
+	ReadTimeout       time.Duration `mapstructure:"read_timeout" default:"60s"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout" default:"60s"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout" default:"120s"`
+
+	// BodyLimit is the maximum request body size in bytes when the
+	// 'body_limit' middleware is enabled. Use httputils.BodyLimit() with
+	// chi's With() to override it for specific routes.
+	BodyLimit int64 `mapstructure:"body_limit" default:"1048576"`
+}
+
+// AccessLogConfig holds the configurations for the access log middleware.
+type AccessLogConfig struct {
+	SampleRate float64  `mapstructure:"sample_rate" default:"1"`
+	Exclude    []string `mapstructure:"exclude" default:"[/health]"`
+}
+
+// CORSConfig holds the configurations for the CORS middleware. Refer
+// httputils.CORSOptions for details.
+type CORSConfig struct {
+	AllowedOrigins   []string          `mapstructure:"allowed_origins"`
+	AllowedMethods   []string          `mapstructure:"allowed_methods" default:"[GET,POST,PUT,PATCH,DELETE]"`
+	AllowedHeaders   []string          `mapstructure:"allowed_headers" default:"[Content-Type,Authorization]"`
+	ExposedHeaders   []string          `mapstructure:"exposed_headers"`
+	AllowCredentials bool              `mapstructure:"allow_credentials"`
+	MaxAge           time.Duration     `mapstructure:"max_age" default:"5m"`
+	Routes           []CORSRouteConfig `mapstructure:"routes"`
+}
+
+// CORSRouteConfig overrides the CORS configs for requests with matching
+// path (exact or prefix if it ends with '*').
+type CORSRouteConfig struct {
+	Path       string `mapstructure:"path"`
+	CORSConfig `mapstructure:",squash"`
+}
+
+func (cfg CORSConfig) options() httputils.CORSOptions {
+	opts := httputils.CORSOptions{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedMethods:   cfg.AllowedMethods,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		ExposedHeaders:   cfg.ExposedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge,
+	}
+	for _, r := range cfg.Routes {
+		opts.Routes = append(opts.Routes, httputils.CORSRoute{
+			Path:    r.Path,
+			Options: r.CORSConfig.options(),
+		})
+	}
+	return opts
+}
+
+// CompressConfig holds the configurations for the compress middleware.
+// Refer httputils.CompressOptions for details.
+type CompressConfig struct {
+	Level        int      `mapstructure:"level" default:"5"`
+	MinSize      int      `mapstructure:"min_size" default:"1024"`
+	ContentTypes []string `mapstructure:"content_types"`
+	Encodings    []string `mapstructure:"encodings" default:"[br,gzip,deflate]"`
+}
+
+func (cfg ServerConfig) timeouts() httputils.Timeouts {
+	return httputils.Timeouts{
+		ReadHeader: cfg.ReadHeaderTimeout,
+		Read:       cfg.ReadTimeout,
+		Write:      cfg.WriteTimeout,
+		Idle:       cfg.IdleTimeout,
+	}
+}
+
+// RateLimitConfig holds the configurations for the rate limit middleware.
+// The built-in middleware uses an in-memory store. Use App.Middlewares
+// with httputils.RateLimit() for shared stores.
+type RateLimitConfig struct {
+	// Algorithm is either 'token_bucket' or 'sliding_window'.
+	Algorithm string `mapstructure:"algorithm" default:"token_bucket"`
+
+	// Limit is the number of requests allowed per Window for each key.
+	Limit  int           `mapstructure:"limit" default:"100"`
+	Window time.Duration `mapstructure:"window" default:"1m"`
+
+	// Burst is the bucket size for 'token_bucket'. Defaults to Limit.
+	Burst int `mapstructure:"burst"`
+
+	// Key is one of 'ip', 'user' or 'header:<name>' (e.g., 'header:X-API-Key').
+	Key string `mapstructure:"key" default:"ip"`
+}
+
+func (cfg RateLimitConfig) options() (httputils.RateLimitOptions, error) {
+	var opts httputils.RateLimitOptions
+
+	key := strings.TrimSpace(cfg.Key)
+	switch {
+	case key == "ip":
+		opts.Key = httputils.KeyByIP
+
+	case key == "user":
+		opts.Key = httputils.KeyByUser
+
+	case strings.HasPrefix(key, "header:") && len(key) > len("header:"):
+		opts.Key = httputils.KeyByHeader(strings.TrimPrefix(key, "header:"))
+
+	default:
+		return opts, fmt.Errorf("invalid rate limit key '%s'", cfg.Key)
+	}
+
+	if cfg.Limit <= 0 || cfg.Window <= 0 {
+		return opts, fmt.Errorf("rate limit and window must be positive")
+	}
+
+	store := httputils.NewMemoryStore()
+	switch cfg.Algorithm {
+	case "token_bucket":
+		opts.Limiter = httputils.NewTokenBucket(cfg.Limit, cfg.Window, cfg.Burst, store)
+
+	case "sliding_window":
+		opts.Limiter = httputils.NewSlidingWindow(cfg.Limit, cfg.Window, store)
+
+	default:
+		return opts, fmt.Errorf("unknown rate limit algorithm '%s'", cfg.Algorithm)
+	}
+	return opts, nil
+}
+
+func (cfg ServerConfig) realIPOptions() (httputils.RealIPOptions, error) {
+	var opts httputils.RealIPOptions
+	if len(cfg.TrustedProxies) == 0 {
+		return opts, fmt.Errorf("real_ip middleware requires trusted_proxies to be set")
+	}
+
+	for _, s := range cfg.TrustedProxies {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			ip, err := netip.ParseAddr(s)
+			if err != nil {
+				return opts, fmt.Errorf("invalid trusted proxy '%s': %w", s, err)
+			}
+			s = fmt.Sprintf("%s/%d", ip.Unmap(), ip.Unmap().BitLen())
+		}
+
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return opts, fmt.Errorf("invalid trusted proxy '%s': %w", s, err)
+		}
+		opts.TrustedProxies = append(opts.TrustedProxies, prefix.Masked())
+	}
+	return opts, nil
+}
+
+// defaultServerConfig returns the server configs with the defaults.
+func defaultServerConfig() ServerConfig {
+	var cfg ServerConfig
+	defaults.SetDefaults(&cfg)
+	return cfg
+}
+
+// serverConfig returns the server configs. Defaults are applied to the
+// App.Server before loading the configs (see loadConfigs) and not here so
+// that the explicitly empty values (e.g., 'middlewares: []') are kept.
+func (app *App) serverConfig() ServerConfig {
+	if app.Server == nil {
+		return defaultServerConfig()
+	}
+	return *app.Server
+}
+
+func (app *App) middlewares(cfg ServerConfig) ([]func(http.Handler) http.Handler, error) {
+	builtins := map[string]func(http.Handler) http.Handler{
+		MiddlewareRecover: httputils.RecoverWith(httputils.RecoverOptions{
+			Reporter: app.PanicReporter,
+		}),
+		MiddlewareRequestID: httputils.RequestID,
+		MiddlewareAccessLog: httputils.AccessLog(httputils.AccessLogOptions{
+			SampleRate: cfg.AccessLog.SampleRate,
+			Exclude:    cfg.AccessLog.Exclude,
+		}),
+		MiddlewareTimeout: httputils.Timeout(cfg.Timeout),
+		MiddlewareCompress: httputils.Compress(httputils.CompressOptions{
+			Level:        cfg.Compress.Level,
+			MinSize:      cfg.Compress.MinSize,
+			ContentTypes: cfg.Compress.ContentTypes,
+			Encodings:    cfg.Compress.Encodings,
+		}),
+		MiddlewareCORS:      httputils.CORS(cfg.CORS.options()),
+		MiddlewareBodyLimit: httputils.BodyLimit(cfg.BodyLimit),
+	}
+
+	var res []func(http.Handler) http.Handler
+	appAdded := false
+	for _, name := range cfg.Middlewares {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		if name == MiddlewareApp {
+			if appAdded {
+				return nil, fmt.Errorf("middleware '%s' is listed more than once", name)
+			}
+			res = append(res, app.Middlewares...)
+			appAdded = true
+			continue
+		}
+
+		if name == MiddlewareRealIP {
+			opts, err := cfg.realIPOptions()
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, httputils.RealIP(opts))
+			continue
+		}
+
+		if name == MiddlewareRateLimit {
+			opts, err := cfg.RateLimit.options()
+			if err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(cfg.RateLimit.Key) == "user" && !appAdded {
+				return nil, fmt.Errorf("rate_limit with 'user' key must be placed after '%s' middleware", MiddlewareApp)
+			}
+			res = append(res, httputils.RateLimit(opts))
+			continue
+		}
+
+		mw, found := builtins[name]
+		if !found {
+			return nil, fmt.Errorf("unknown middleware '%s'", name)
+		}
+		res = append(res, mw)
+	}
+
+	if !appAdded {
+		res = append(res, app.Middlewares...)
+	}
+	return res, nil
+}
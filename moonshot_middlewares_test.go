@@ -0,0 +1,101 @@
+package moonshot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/httputils"
+)
+
+func TestRateLimit_UserKey(t *testing.T) {
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+			if user := req.Header.Get("X-User"); user != "" {
+				req = req.WithContext(httputils.WithIdentity(req.Context(), httputils.Identity{Subject: user}))
+			}
+			next.ServeHTTP(wr, req)
+		})
+	}
+
+	newApp := func(middlewares ...string) *App {
+		cfg := defaultServerConfig()
+		cfg.Middlewares = middlewares
+		cfg.RateLimit.Key, cfg.RateLimit.Limit, cfg.RateLimit.Window = "user", 1, time.Minute
+		return &App{
+			Middlewares: []func(http.Handler) http.Handler{auth},
+			Routes: func(r *chi.Mux) error {
+				r.Get("/ping", func(wr http.ResponseWriter, req *http.Request) {})
+				return nil
+			},
+			Server: &cfg,
+		}
+	}
+
+	t.Run("BeforeApp", func(t *testing.T) {
+		app := newApp(MiddlewareRateLimit)
+		_, err := app.buildRouter(app.serverConfig())
+		assert.Error(t, err)
+
+		app = newApp(MiddlewareRateLimit, MiddlewareApp)
+		_, err = app.buildRouter(app.serverConfig())
+		assert.Error(t, err)
+	})
+
+	t.Run("AfterApp", func(t *testing.T) {
+		app := newApp(MiddlewareApp, MiddlewareRateLimit)
+		router, err := app.buildRouter(app.serverConfig())
+		require.NoError(t, err)
+
+		do := func(user string) int {
+			req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+			req.Header.Set("X-User", user)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			return rec.Code
+		}
+		assert.Equal(t, http.StatusOK, do("alice"))
+		assert.Equal(t, http.StatusTooManyRequests, do("alice"))
+		assert.Equal(t, http.StatusOK, do("bob"))
+	})
+}
+
+func TestServerConfig_EmptyValues(t *testing.T) {
+	var cfg struct {
+		Server ServerConfig `mapstructure:"server"`
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+server:
+  middlewares: []
+  access_log:
+    exclude: []
+  compress:
+    level: 0
+`), 0o600))
+
+	app := &App{Name: "test", CfgPtr: &cfg, Server: &cfg.Server}
+	cmd := &cobra.Command{}
+	cmd.Flags().String("config", path, "")
+	require.NoError(t, app.loadConfigs(cmd))
+
+	got := app.serverConfig()
+	assert.Empty(t, got.Middlewares)
+	assert.NotNil(t, got.Middlewares)
+	assert.Empty(t, got.AccessLog.Exclude)
+	assert.Equal(t, 0, got.Compress.Level)
+	assert.Equal(t, 30*time.Second, got.Timeout, "defaults must be applied to the omitted values")
+
+	mws, err := app.middlewares(got)
+	require.NoError(t, err)
+	assert.Empty(t, mws)
+}
@@ -0,0 +1,132 @@
+package moonshot
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/go-chi/chi"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/httputils"
+	"github.com/spy16/moonshot/log"
+	"github.com/spy16/moonshot/openapi"
+)
+
+const (
+	openAPIRoute    = "/openapi.json"
+	docsRoute       = "/docs"
+	docsAssetsRoute = "/docs/assets/"
+)
+
+// docsAssets holds the script and styles of the API reference page. They
+// are served by the app so that the page works without internet access.
+//
+//go:embed assets/docs
+var docsAssets embed.FS
+
+var docsPage = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<title>{{.Title}} - API Reference</title>
+	<meta charset="utf-8"/>
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<link rel="stylesheet" href="{{.AssetsURL}}docs.css">
+</head>
+<body>
+	<div id="docs" data-spec-url="{{.SpecURL}}">Loading...</div>
+	<script src="{{.AssetsURL}}docs.js"></script>
+</body>
+</html>
+`))
+
+func (app *App) cmdOpenAPI(ctx context.Context) *cobra.Command {
+	var format string
+	cmd := &cobra.Command{
+		Use:   "openapi",
+		Short: "Print the OpenAPI document of the HTTP API",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := app.loadConfigs(cmd); err != nil {
+				log.Fatalf(ctx, "failed to load configurations: %v", err)
+			}
+
+			router, err := app.buildRouter(app.serverConfig())
+			if err != nil {
+				log.Fatalf(ctx, "server setup failed: %v", err)
+			}
+
+			doc, err := openapi.Generate(router, app.apiInfo())
+			if err != nil {
+				log.Fatalf(ctx, "failed to generate openapi document: %v", err)
+			}
+
+			if format == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				err = enc.Encode(doc)
+			} else if format == "yaml" || format == "yml" {
+				err = yaml.NewEncoder(os.Stdout).Encode(doc)
+			} else {
+				err = errors.New("unknown format")
+			}
+
+			if err != nil {
+				log.Fatalf(ctx, "failed to display openapi document: %v", err)
+			}
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", "json", "Output format")
+	return cmd
+}
+
+// mountDocs registers the OpenAPI document and the API reference page.
+// Document is generated on the first request so that it includes all
+// the routes registered after this.
+func (app *App) mountDocs(router *chi.Mux) {
+	var once sync.Once
+	var doc *openapi.Document
+	var genErr error
+
+	router.Get(openAPIRoute, func(wr http.ResponseWriter, req *http.Request) {
+		once.Do(func() {
+			doc, genErr = openapi.Generate(router, app.apiInfo())
+		})
+		if genErr != nil {
+			httputils.Respond(wr, req, http.StatusInternalServerError,
+				errors.ErrInternal.WithCausef("failed to generate openapi document: %v", genErr))
+			return
+		}
+		httputils.Respond(wr, req, http.StatusOK, doc)
+	})
+
+	router.Get(docsRoute, func(wr http.ResponseWriter, req *http.Request) {
+		wr.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = docsPage.Execute(wr, map[string]string{
+			"Title":     app.Name,
+			"SpecURL":   openAPIRoute,
+			"AssetsURL": docsAssetsRoute,
+		})
+	})
+
+	assets, _ := fs.Sub(docsAssets, "assets/docs")
+	router.Get(docsAssetsRoute+"*", http.StripPrefix(docsAssetsRoute, http.FileServer(http.FS(assets))).ServeHTTP)
+}
+
+func (app *App) apiInfo() openapi.Info {
+	desc := app.Long
+	if desc == "" {
+		desc = app.Short
+	}
+	return openapi.Info{
+		Title:       app.Name,
+		Description: desc,
+		Version:     app.Version,
+	}
+}
@@ -0,0 +1,46 @@
+package moonshot
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocs(t *testing.T) {
+	app := &App{Name: "test"}
+	router, err := app.buildRouter(app.serverConfig())
+	require.NoError(t, err)
+
+	// real server since file server needs the io.ReaderFrom support.
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	table := []struct {
+		title           string
+		path            string
+		wantContentType string
+		wantContains    string
+	}{
+		{title: "Page", path: "/docs", wantContentType: "text/html; charset=utf-8", wantContains: `src="/docs/assets/docs.js"`},
+		{title: "Script", path: "/docs/assets/docs.js", wantContentType: "text/javascript; charset=utf-8", wantContains: "data-spec-url"},
+		{title: "Styles", path: "/docs/assets/docs.css", wantContentType: "text/css; charset=utf-8", wantContains: "#docs"},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.title, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tt.path)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, tt.wantContentType, resp.Header.Get("Content-Type"))
+			assert.Contains(t, string(body), tt.wantContains)
+			assert.NotContains(t, string(body), "https://", "docs must not load remote assets")
+		})
+	}
+}
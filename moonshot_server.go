@@ -2,6 +2,7 @@ package moonshot
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"io/fs"
@@ -9,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,8 +23,9 @@ import (
 )
 
 func (app *App) cmdServe(ctx context.Context) *cobra.Command {
-	var graceDur time.Duration
-	var addr, staticDir, staticRoute string
+	var graceDur, logRevertAfter time.Duration
+	var tlsSelfSigned bool
+	var addr, grpcAddr, socketMode, staticDir, staticRoute, tlsCert, tlsKey string
 	cmd := &cobra.Command{
 		Use:   "serve",
 		Short: "Start HTTP server.",
@@ -32,15 +35,10 @@ func (app *App) cmdServe(ctx context.Context) *cobra.Command {
 				return
 			}
 
-			router := chi.NewRouter()
-			router.NotFound(notFoundHandler())
-			router.MethodNotAllowed(methodNotAllowedHandler())
-			router.Get("/health", pingHandler(map[string]interface{}{
-				"status": "ok",
-			}))
-
-			if err := app.Routes(router); err != nil {
-				log.Fatalf(ctx, "route setup failed: %v", err)
+			srvCfg := app.serverConfig()
+			router, err := app.buildRouter(srvCfg)
+			if err != nil {
+				log.Fatalf(ctx, "server setup failed: %v", err)
 			}
 
 			if app.StaticFS != nil {
@@ -49,20 +47,127 @@ func (app *App) cmdServe(ctx context.Context) *cobra.Command {
 				router.Mount(staticRoute, http.StripPrefix(staticRoute, http.FileServer(http.Dir(staticDir))))
 			}
 
+			watchLevelSignals(ctx, logRevertAfter)
+
+			mode, err := strconv.ParseUint(socketMode, 8, 32)
+			if err != nil {
+				log.Fatalf(ctx, "invalid socket mode '%s': %v", socketMode, err)
+			}
+			listenOpts := []httputils.ListenOption{httputils.WithSocketMode(os.FileMode(mode))}
+
+			var handler http.Handler = router
+			serveOpts := []httputils.ServeOption{httputils.WithTimeouts(srvCfg.timeouts())}
+
+			tlsCfg := srvCfg.TLS
+			if tlsCert != "" || tlsKey != "" {
+				tlsCfg.Cert, tlsCfg.Key = tlsCert, tlsKey
+			}
+			tlsCfg.SelfSigned = tlsCfg.SelfSigned || tlsSelfSigned
+
+			var tlsConf *tls.Config
+			if tlsCfg.enabled() {
+				tlsConf, err = httputils.NewTLSConfig(ctx, tlsCfg.options())
+				if err != nil {
+					log.Fatalf(ctx, "tls setup failed: %v", err)
+				}
+				if tlsCfg.Cert == "" && tlsCfg.Key == "" {
+					log.Warnf(ctx, "using a self-signed tls certificate (not for production use)")
+				}
+				serveOpts = append(serveOpts, httputils.WithTLS(tlsConf))
+				if len(tlsCfg.ClientCA) > 0 {
+					handler = httputils.ClientCertIdentity(handler)
+				}
+			}
+
+			errCh := make(chan error, 2)
+			servers := 1
+			if app.GRPC != nil {
+				grpcTLS := tlsConf
+				if grpcAddr == "" {
+					// TLS is handled by the HTTP server.
+					grpcTLS = nil
+				}
+
+				grpcSrv, err := app.newGRPCServer(srvCfg.GRPC, grpcTLS)
+				if err != nil {
+					log.Fatalf(ctx, "server setup failed: %v", err)
+				}
+
+				if grpcAddr != "" {
+					grpcLis, err := httputils.Listen(grpcAddr, listenOpts...)
+					if err != nil {
+						log.Fatalf(ctx, "failed to listen on '%s': %v", grpcAddr, err)
+					}
+
+					log.Infof(ctx, "starting grpc server at '%s'...", grpcAddr)
+					go func() { errCh <- grpcSrv.Serve(ctx, graceDur, grpcLis) }()
+					servers++
+				} else {
+					handler = grpcSrv.Handler(ctx, handler)
+					serveOpts = append(serveOpts, httputils.WithH2C())
+				}
+			}
+
+			lis, err := httputils.Listen(addr, listenOpts...)
+			if err != nil {
+				log.Fatalf(ctx, "failed to listen on '%s': %v", addr, err)
+			}
+
 			log.Infof(ctx, "starting server at '%s'...", addr)
-			if err := httputils.GracefulServe(ctx, graceDur, addr, router); err != nil {
-				log.Fatalf(ctx, "server exited with error: %v", err)
+			go func() { errCh <- httputils.GracefulServe(ctx, graceDur, lis, handler, serveOpts...) }()
+
+			for i := 0; i < servers; i++ {
+				if err := <-errCh; err != nil {
+					log.Fatalf(ctx, "server exited with error: %v", err)
+				}
 			}
 		},
 	}
 
-	cmd.Flags().StringVarP(&addr, "addr", "a", ":8080", "Bind address for HTTP server")
+	cmd.Flags().StringVarP(&addr, "addr", "a", ":8080", "Bind address for HTTP server (host:port, unix:///path/to/app.sock or systemd://[name])")
+	cmd.Flags().StringVar(&grpcAddr, "grpc-addr", "", "Bind address for gRPC server (serves on --addr if empty)")
+	cmd.Flags().StringVar(&socketMode, "socket-mode", "0660", "File mode for the unix sockets")
+	cmd.Flags().StringVar(&tlsCert, "tls-cert", "", "TLS certificate file (reloaded on change)")
+	cmd.Flags().StringVar(&tlsKey, "tls-key", "", "TLS private key file (reloaded on change)")
+	cmd.Flags().BoolVar(&tlsSelfSigned, "tls-self-signed", false, "Serve TLS using a generated self-signed certificate (development only)")
 	cmd.Flags().StringVarP(&staticDir, "static-dir", "D", "", "Directory to serve static files from")
 	cmd.Flags().StringVarP(&staticRoute, "static-route", "R", "/", "Route to serve static files under")
 	cmd.Flags().DurationVarP(&graceDur, "grace-period", "G", 5*time.Second, "Grace period for shutdown")
+	cmd.Flags().DurationVar(&logRevertAfter, "log-revert-after", 0, "Revert log level changed via SIGUSR1/SIGUSR2 after this duration (0 to disable)")
 	return cmd
 }
 
+// buildRouter returns the router with the middlewares, built-in routes
+// and the app routes registered.
+func (app *App) buildRouter(cfg ServerConfig) (*chi.Mux, error) {
+	mws, err := app.middlewares(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("middleware setup failed: %w", err)
+	}
+
+	router := chi.NewRouter()
+	router.Use(mws...)
+	router.NotFound(notFoundHandler())
+	router.MethodNotAllowed(methodNotAllowedHandler())
+	router.Get("/health", pingHandler(map[string]interface{}{
+		"status": "ok",
+	}))
+	app.mountAdmin(router)
+	if !cfg.DisableDocs {
+		app.mountDocs(router)
+	}
+	if app.GraphQL != nil {
+		app.mountGraphQL(router, cfg.GraphQL)
+	}
+
+	if app.Routes != nil {
+		if err := app.Routes(router); err != nil {
+			return nil, fmt.Errorf("route setup failed: %w", err)
+		}
+	}
+	return router, nil
+}
+
 func methodNotAllowedHandler() http.HandlerFunc {
 	return func(wr http.ResponseWriter, req *http.Request) {
 		httputils.Respond(wr, req, http.StatusMethodNotAllowed,
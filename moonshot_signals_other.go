@@ -0,0 +1,11 @@
+//go:build !unix
+
+package moonshot
+
+import (
+	"context"
+	"time"
+)
+
+// watchLevelSignals is a no-op on platforms without SIGUSR1/SIGUSR2.
+func watchLevelSignals(ctx context.Context, revertAfter time.Duration) {}
@@ -0,0 +1,46 @@
+//go:build unix
+
+package moonshot
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spy16/moonshot/log"
+)
+
+// watchLevelSignals changes the global log level on SIGUSR1 (more verbose)
+// and SIGUSR2 (less verbose). If revertAfter is positive, the level is
+// reverted automatically after the duration.
+func watchLevelSignals(ctx context.Context, revertAfter time.Duration) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		defer signal.Stop(sigCh)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case sig := <-sigCh:
+				step := 1
+				if sig == syscall.SIGUSR1 {
+					step = -1
+				}
+
+				lvl := stepLevel(log.GetLevel(), step)
+				if revertAfter > 0 {
+					log.SetLevelFor("", lvl, revertAfter)
+				} else {
+					log.SetLevel(lvl)
+				}
+				log.Warnf(ctx, "log level changed to '%s' (signal: %s)", lvl, sig)
+			}
+		}
+	}()
+}
@@ -0,0 +1,49 @@
+package moonshot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/log"
+	"github.com/spy16/moonshot/log/logtest"
+)
+
+func TestRedactOption(t *testing.T) {
+	t.Cleanup(func() { log.Setup("info", "text") })
+
+	table := []struct {
+		mode    string
+		wantErr bool
+		want    log.Fields
+	}{
+		{mode: "all", want: log.Fields{"password": "[REDACTED]", "note": "card [REDACTED]"}},
+		{mode: "keys", want: log.Fields{"password": "[REDACTED]", "note": "card 4111 1111 1111 1111"}},
+		{mode: "none", want: log.Fields{"password": "hunter2", "note": "card 4111 1111 1111 1111"}},
+		{mode: "some", wantErr: true},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.mode, func(t *testing.T) {
+			opt, err := redactOption(tt.mode)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			rec := &logtest.Recorder{}
+			opts := []log.Option{log.WithHandler(rec)}
+			if opt != nil {
+				opts = append(opts, opt)
+			}
+			require.NoError(t, log.Configure("info", "text", opts...))
+
+			log.Info(context.Background(), "login", "password", "hunter2", "note", "card 4111 1111 1111 1111")
+			require.Len(t, rec.Entries(), 1)
+			assert.Equal(t, tt.want, rec.Entries()[0].Fields)
+		})
+	}
+}
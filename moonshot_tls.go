@@ -0,0 +1,42 @@
+package moonshot
+
+import (
+	"time"
+
+	"github.com/spy16/moonshot/httputils"
+)
+
+// TLSConfig holds the configurations for serving HTTPS (and gRPC) with TLS.
+// Refer httputils.TLSOptions for details. The '--tls-cert', '--tls-key'
+// and '--tls-self-signed' flags of 'serve' override these.
+type TLSConfig struct {
+	Cert       string   `mapstructure:"cert"`
+	Key        string   `mapstructure:"key"`
+	SelfSigned bool     `mapstructure:"self_signed"`
+	Hosts      []string `mapstructure:"hosts"`
+
+	// ClientCA enables client certificate verification (mTLS) using the
+	// CA bundles. The certificate subject is set as the request identity.
+	ClientCA           []string `mapstructure:"client_ca"`
+	ClientCertOptional bool     `mapstructure:"client_cert_optional"`
+
+	// ReloadInterval is the interval at which the certificate files are
+	// checked for changes. Set a negative value to disable.
+	ReloadInterval time.Duration `mapstructure:"reload_interval" default:"10s"`
+}
+
+func (cfg TLSConfig) enabled() bool {
+	return cfg.Cert != "" || cfg.Key != "" || cfg.SelfSigned
+}
+
+func (cfg TLSConfig) options() httputils.TLSOptions {
+	return httputils.TLSOptions{
+		CertFile:           cfg.Cert,
+		KeyFile:            cfg.Key,
+		SelfSigned:         cfg.SelfSigned,
+		Hosts:              cfg.Hosts,
+		ClientCAFiles:      cfg.ClientCA,
+		ClientCertOptional: cfg.ClientCertOptional,
+		ReloadInterval:     cfg.ReloadInterval,
+	}
+}
@@ -0,0 +1,242 @@
+// Package openapi generates OpenAPI 3.1 documents from the routes
+// registered on a chi router. Only handlers implementing the
+// httputils.Describer interface (e.g., created using httputils.JSON())
+// are included.
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/httputils"
+)
+
+// Version is the OpenAPI specification version of the generated documents.
+const Version = "3.1.0"
+
+// Document represents an OpenAPI document.
+type Document struct {
+	OpenAPI    string               `json:"openapi" yaml:"openapi"`
+	Info       Info                 `json:"info" yaml:"info"`
+	Paths      map[string]*PathItem `json:"paths" yaml:"paths"`
+	Components Components           `json:"components" yaml:"components"`
+}
+
+// Info holds the metadata about the API.
+type Info struct {
+	Title       string `json:"title" yaml:"title"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Version     string `json:"version" yaml:"version"`
+}
+
+// PathItem holds the operations available on a path.
+type PathItem struct {
+	Get     *Operation `json:"get,omitempty" yaml:"get,omitempty"`
+	Put     *Operation `json:"put,omitempty" yaml:"put,omitempty"`
+	Post    *Operation `json:"post,omitempty" yaml:"post,omitempty"`
+	Delete  *Operation `json:"delete,omitempty" yaml:"delete,omitempty"`
+	Options *Operation `json:"options,omitempty" yaml:"options,omitempty"`
+	Head    *Operation `json:"head,omitempty" yaml:"head,omitempty"`
+	Patch   *Operation `json:"patch,omitempty" yaml:"patch,omitempty"`
+	Trace   *Operation `json:"trace,omitempty" yaml:"trace,omitempty"`
+}
+
+// Operation describes a single API operation on a path.
+type Operation struct {
+	OperationID string               `json:"operationId" yaml:"operationId"`
+	Summary     string               `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Description string               `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Deprecated  bool                 `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses" yaml:"responses"`
+}
+
+// Parameter describes a path, query or header parameter.
+type Parameter struct {
+	Name     string  `json:"name" yaml:"name"`
+	In       string  `json:"in" yaml:"in"`
+	Required bool    `json:"required,omitempty" yaml:"required,omitempty"`
+	Schema   *Schema `json:"schema" yaml:"schema"`
+}
+
+// RequestBody describes the request body of an operation.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty" yaml:"required,omitempty"`
+	Content  map[string]MediaType `json:"content" yaml:"content"`
+}
+
+// Response describes a response of an operation.
+type Response struct {
+	Description string               `json:"description" yaml:"description"`
+	Content     map[string]MediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+// MediaType holds the schema for a content-type.
+type MediaType struct {
+	Schema *Schema `json:"schema" yaml:"schema"`
+}
+
+// Components holds the reusable schemas.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas" yaml:"schemas"`
+}
+
+// Generate walks the routes and returns an OpenAPI document describing the
+// operations of handlers that implement httputils.Describer.
+func Generate(routes chi.Routes, info Info) (*Document, error) {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]*PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{errorSchemaName: errorSchema()},
+		},
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "0.0.0"
+	}
+
+	reg := newRegistry(doc.Components.Schemas)
+	err := chi.Walk(routes, func(method, route string, h http.Handler, _ ...func(http.Handler) http.Handler) error {
+		d, ok := h.(httputils.Describer)
+		if !ok {
+			return nil
+		}
+
+		path, pathParams := normalisePath(route)
+		item := doc.Paths[path]
+		if item == nil {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+
+		op := buildOperation(reg, method, path, pathParams, d.Describe())
+		item.set(method, op)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (pi *PathItem) set(method string, op *Operation) {
+	switch method {
+	case http.MethodGet:
+		pi.Get = op
+	case http.MethodPut:
+		pi.Put = op
+	case http.MethodPost:
+		pi.Post = op
+	case http.MethodDelete:
+		pi.Delete = op
+	case http.MethodOptions:
+		pi.Options = op
+	case http.MethodHead:
+		pi.Head = op
+	case http.MethodPatch:
+		pi.Patch = op
+	case http.MethodTrace:
+		pi.Trace = op
+	}
+}
+
+func buildOperation(reg *registry, method, path string, pathParams []string, desc httputils.Operation) *Operation {
+	op := &Operation{
+		OperationID: operationID(method, path),
+		Summary:     desc.Summary,
+		Description: desc.Description,
+		Tags:        desc.Tags,
+		Deprecated:  desc.Deprecated,
+		Responses:   map[string]*Response{},
+	}
+
+	if desc.Request != nil {
+		op.Parameters, op.RequestBody = reg.requestOf(desc.Request, method)
+	}
+	op.Parameters = addPathParams(op.Parameters, pathParams)
+
+	status := desc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &Response{Description: http.StatusText(status)}
+	if desc.Response != nil && status != http.StatusNoContent {
+		success.Content = map[string]MediaType{
+			"application/json": {Schema: reg.schemaOf(desc.Response)},
+		}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+
+	errs := desc.Errors
+	if desc.Request != nil {
+		errs = append([]errors.Error{errors.ErrInvalid}, errs...)
+	}
+	for _, e := range errs {
+		code := strconv.Itoa(httputils.ErrorStatus(e))
+		if existing, found := op.Responses[code]; found {
+			if !strings.Contains(existing.Description, e.Message) {
+				existing.Description += "; " + e.Message
+			}
+			continue
+		}
+		op.Responses[code] = errorResponse(e.Message)
+	}
+	op.Responses["default"] = errorResponse("Unexpected error")
+	return op
+}
+
+// addPathParams adds the path params not already declared by the request
+// type as string params.
+func addPathParams(params []Parameter, names []string) []Parameter {
+	for _, name := range names {
+		found := false
+		for _, p := range params {
+			if p.In == "path" && p.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			params = append(params, Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+	return params
+}
+
+var (
+	regexpParam   = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+	nonIdentChars = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// normalisePath converts chi route pattern to OpenAPI path (i.e., strips
+// the regexp from params) and returns the path param names.
+func normalisePath(route string) (string, []string) {
+	var params []string
+	path := regexpParam.ReplaceAllStringFunc(route, func(m string) string {
+		name := regexpParam.FindStringSubmatch(m)[1]
+		params = append(params, name)
+		return "{" + name + "}"
+	})
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return path, params
+}
+
+func operationID(method, path string) string {
+	id := nonIdentChars.ReplaceAllString(strings.ToLower(method)+"_"+path, "_")
+	return strings.Trim(id, "_")
+}
@@ -0,0 +1,137 @@
+package openapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/httputils"
+	"github.com/spy16/moonshot/openapi"
+)
+
+type createUserReq struct {
+	Org    string   `json:"-" path:"org"`
+	DryRun bool     `json:"-" query:"dry_run"`
+	Name   string   `json:"name" validate:"required,min=2,max=64"`
+	Email  string   `json:"email" validate:"email"`
+	Role   string   `json:"role" validate:"oneof=admin member"`
+	Labels []string `json:"labels" validate:"max=5"`
+}
+
+type user struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Manager   *user     `json:"manager,omitempty"`
+	Tags      map[string]string
+	internal  string
+}
+
+type listUsersReq struct {
+	Org   string `path:"org"`
+	Limit int    `query:"limit" validate:"min=1,max=100"`
+}
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	createUser := func(ctx context.Context, req createUserReq) (user, error) { return user{}, nil }
+	listUsers := func(ctx context.Context, req listUsersReq) ([]user, error) { return nil, nil }
+
+	router := chi.NewRouter()
+	router.Get("/health", func(wr http.ResponseWriter, req *http.Request) {})
+	router.Route("/orgs/{org:[a-z]+}", func(r chi.Router) {
+		r.Method(http.MethodPost, "/users", httputils.JSON(createUser,
+			httputils.WithStatus(http.StatusCreated),
+			httputils.WithSummary("Create user", "Creates a user in the org."),
+			httputils.WithTags("users"),
+			httputils.WithErrors(errors.ErrConflict, errors.ErrForbidden)))
+		r.With(httputils.Timeout(time.Second)).Method(http.MethodGet, "/users", httputils.JSON(listUsers))
+		r.Method(http.MethodDelete, "/users/{id}", httputils.Describe(
+			http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {}),
+			httputils.Operation{Summary: "Delete user", Status: http.StatusNoContent},
+		))
+	})
+
+	doc, err := openapi.Generate(router, openapi.Info{Title: "test"})
+	require.NoError(t, err)
+
+	// round-trip through JSON to compare against the expected structure.
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &got))
+
+	assert.Equal(t, "3.1.0", got["openapi"])
+	assert.Equal(t, map[string]interface{}{"title": "test", "version": "0.0.0"}, got["info"])
+
+	paths := got["paths"].(map[string]interface{})
+	require.Len(t, paths, 2)
+
+	users := paths["/orgs/{org}/users"].(map[string]interface{})
+	create := users["post"].(map[string]interface{})
+	assert.Equal(t, "post_orgs_org_users", create["operationId"])
+	assert.Equal(t, "Create user", create["summary"])
+	assert.Equal(t, []interface{}{"users"}, create["tags"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "org", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}},
+		map[string]interface{}{"name": "dry_run", "in": "query", "schema": map[string]interface{}{"type": "boolean"}},
+	}, create["parameters"])
+
+	body := create["requestBody"].(map[string]interface{})
+	assert.Equal(t, true, body["required"])
+	assert.Equal(t, map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"name"},
+		"properties": map[string]interface{}{
+			"name":   map[string]interface{}{"type": "string", "minLength": 2.0, "maxLength": 64.0},
+			"email":  map[string]interface{}{"type": "string", "format": "email"},
+			"role":   map[string]interface{}{"type": "string", "enum": []interface{}{"admin", "member"}},
+			"labels": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "maxItems": 5.0},
+		},
+	}, body["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"])
+
+	responses := create["responses"].(map[string]interface{})
+	assert.ElementsMatch(t, []string{"201", "400", "403", "409", "default"}, keys(responses))
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/user"},
+		responses["201"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"])
+
+	list := users["get"].(map[string]interface{})
+	assert.Nil(t, list["requestBody"])
+	assert.Equal(t, map[string]interface{}{"type": "integer", "format": "int32", "minimum": 1.0, "maximum": 100.0},
+		list["parameters"].([]interface{})[1].(map[string]interface{})["schema"])
+	assert.Equal(t, map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/components/schemas/user"}},
+		list["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"])
+
+	del := paths["/orgs/{org}/users/{id}"].(map[string]interface{})["delete"].(map[string]interface{})
+	assert.Len(t, del["parameters"], 2)
+	assert.ElementsMatch(t, []string{"204", "default"}, keys(del["responses"].(map[string]interface{})))
+
+	schemas := got["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	assert.ElementsMatch(t, []string{"Error", "user"}, keys(schemas))
+	assert.Equal(t, map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id":         map[string]interface{}{"type": "string"},
+			"name":       map[string]interface{}{"type": "string"},
+			"created_at": map[string]interface{}{"type": "string", "format": "date-time"},
+			"manager":    map[string]interface{}{"$ref": "#/components/schemas/user"},
+			"Tags":       map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}},
+		},
+	}, schemas["user"])
+}
+
+func keys(m map[string]interface{}) []string {
+	var res []string
+	for k := range m {
+		res = append(res, k)
+	}
+	return res
+}
@@ -0,0 +1,393 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const errorSchemaName = "Error"
+
+// Schema is a JSON Schema (2020-12) object as used by OpenAPI 3.1.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty" yaml:"type,omitempty"`
+	Format               string             `json:"format,omitempty" yaml:"format,omitempty"`
+	Description          string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Items                *Schema            `json:"items,omitempty" yaml:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty" yaml:"required,omitempty"`
+	Enum                 []string           `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty" yaml:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty" yaml:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty" yaml:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty" yaml:"maxItems,omitempty"`
+}
+
+const schemaRefPrefix = "#/components/schemas/"
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+	paramSources       = []string{"path", "query", "header"}
+	methodsWithoutBody = map[string]bool{http.MethodGet: true, http.MethodHead: true, http.MethodOptions: true}
+)
+
+// registry generates schemas for types and registers the named struct
+// types as reusable component schemas.
+type registry struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newRegistry(schemas map[string]*Schema) *registry {
+	return &registry{schemas: schemas, names: map[reflect.Type]string{}}
+}
+
+// requestOf returns the parameters and the request body for the request
+// type. Fields with 'path', 'query' or 'header' tags are parameters, and
+// the rest are part of the JSON body (and form body if 'form' tags are
+// used).
+func (reg *registry) requestOf(t reflect.Type, method string) ([]Parameter, *RequestBody) {
+	t = deref(t)
+	if t.Kind() != reflect.Struct {
+		if methodsWithoutBody[method] || t.Kind() == reflect.Interface {
+			return nil, nil
+		}
+		return nil, &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: reg.schemaOf(t)}},
+		}
+	}
+
+	var params []Parameter
+	body := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	form := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	eachField(t, func(sf reflect.StructField) {
+		isParam := false
+		for _, src := range paramSources {
+			name := tagName(sf, src)
+			if name == "" {
+				continue
+			}
+			isParam = true
+
+			schema := paramSchema(sf.Type)
+			applyRules(schema, sf)
+			params = append(params, Parameter{
+				Name:     name,
+				In:       src,
+				Required: src == "path" || isRequired(sf),
+				Schema:   schema,
+			})
+		}
+
+		if name := tagName(sf, "form"); name != "" {
+			schema := paramSchema(sf.Type)
+			applyRules(schema, sf)
+			form.Properties[name] = schema
+			if isRequired(sf) {
+				form.Required = append(form.Required, name)
+			}
+		}
+
+		// fields bound from params or form are not part of JSON body unless
+		// explicitly tagged.
+		name, hasJSONTag := jsonName(sf)
+		if name == "" || ((isParam || tagName(sf, "form") != "") && !hasJSONTag) {
+			return
+		}
+		body.Properties[name] = reg.fieldSchema(sf)
+		if isRequired(sf) {
+			body.Required = append(body.Required, name)
+		}
+	})
+
+	if methodsWithoutBody[method] || (len(body.Properties) == 0 && len(form.Properties) == 0) {
+		return params, nil
+	}
+
+	rb := &RequestBody{Required: len(body.Required) > 0, Content: map[string]MediaType{}}
+	if len(body.Properties) > 0 {
+		rb.Content["application/json"] = MediaType{Schema: body}
+	}
+	if len(form.Properties) > 0 {
+		rb.Content["application/x-www-form-urlencoded"] = MediaType{Schema: form}
+	}
+	return params, rb
+}
+
+// schemaOf returns the schema for the type as used in JSON bodies. Named
+// struct types are registered as components and referenced.
+func (reg *registry) schemaOf(t reflect.Type) *Schema {
+	t = deref(t)
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+
+	case t == rawMessageType, t.Kind() == reflect.Interface:
+		return &Schema{}
+
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// custom encoding. nothing can be inferred.
+		return &Schema{}
+
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.Name() == "" {
+			return reg.structSchema(t)
+		}
+		return &Schema{Ref: schemaRefPrefix + reg.register(t)}
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: reg.schemaOf(t.Elem())}
+
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: reg.schemaOf(t.Elem())}
+
+	default:
+		return primitiveSchema(t)
+	}
+}
+
+// register adds the schema for the named struct type to components and
+// returns the name used.
+func (reg *registry) register(t reflect.Type) string {
+	if name, found := reg.names[t]; found {
+		return name
+	}
+
+	base := strings.Trim(nonIdentChars.ReplaceAllString(t.Name(), "_"), "_")
+	name := base
+	for i := 2; ; i++ {
+		if _, taken := reg.schemas[name]; !taken {
+			break
+		}
+		name = base + strconv.Itoa(i)
+	}
+
+	// register before generating to support recursive types.
+	reg.names[t] = name
+	reg.schemas[name] = &Schema{}
+	*reg.schemas[name] = *reg.structSchema(t)
+	return name
+}
+
+func (reg *registry) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	eachField(t, func(sf reflect.StructField) {
+		name, _ := jsonName(sf)
+		if name == "" {
+			return
+		}
+		s.Properties[name] = reg.fieldSchema(sf)
+		if isRequired(sf) {
+			s.Required = append(s.Required, name)
+		}
+	})
+	return s
+}
+
+func (reg *registry) fieldSchema(sf reflect.StructField) *Schema {
+	schema := reg.schemaOf(sf.Type)
+	if schema.Ref != "" {
+		return schema
+	}
+	applyRules(schema, sf)
+	return schema
+}
+
+// paramSchema returns the schema for path, query, header and form values.
+func paramSchema(t reflect.Type) *Schema {
+	t = deref(t)
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == durationType:
+		return &Schema{Type: "string", Format: "duration"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8:
+		return &Schema{Type: "array", Items: paramSchema(t.Elem())}
+	}
+	return primitiveSchema(t)
+}
+
+func primitiveSchema(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: float(0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	default:
+		return &Schema{Type: "string"}
+	}
+}
+
+// applyRules adds the constraints from the 'validate' tag to the schema.
+// See httputils.Validate for the supported rules.
+func applyRules(s *Schema, sf reflect.StructField) {
+	for _, rule := range strings.Split(sf.Tag.Get("validate"), ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		n, err := strconv.ParseFloat(arg, 64)
+		hasNum := err == nil
+
+		switch {
+		case name == "email":
+			s.Format = "email"
+
+		case name == "oneof":
+			s.Enum = strings.Fields(arg)
+
+		case hasNum && (name == "min" || name == "max" || name == "len"):
+			applyBound(s, name, n)
+		}
+	}
+}
+
+func applyBound(s *Schema, rule string, n float64) {
+	isMin := rule == "min" || rule == "len"
+	isMax := rule == "max" || rule == "len"
+
+	switch s.Type {
+	case "string":
+		if isMin {
+			s.MinLength = intPtr(n)
+		}
+		if isMax {
+			s.MaxLength = intPtr(n)
+		}
+
+	case "array":
+		if isMin {
+			s.MinItems = intPtr(n)
+		}
+		if isMax {
+			s.MaxItems = intPtr(n)
+		}
+
+	case "integer", "number":
+		if rule == "min" {
+			s.Minimum = float(n)
+		}
+		if rule == "max" {
+			s.Maximum = float(n)
+		}
+	}
+}
+
+func errorSchema() *Schema {
+	str := &Schema{Type: "string"}
+	return &Schema{
+		Type:     "object",
+		Required: []string{"code", "message"},
+		Properties: map[string]*Schema{
+			"code":       str,
+			"cause":      str,
+			"message":    str,
+			"request_id": str,
+			"fields": {
+				Type: "array",
+				Items: &Schema{
+					Type:       "object",
+					Required:   []string{"field", "reason"},
+					Properties: map[string]*Schema{"field": str, "reason": str},
+				},
+			},
+		},
+	}
+}
+
+func errorResponse(desc string) *Response {
+	return &Response{
+		Description: desc,
+		Content: map[string]MediaType{
+			"application/json": {Schema: &Schema{Ref: schemaRefPrefix + errorSchemaName}},
+		},
+	}
+}
+
+// eachField calls fn for the exported fields of the struct type including
+// the fields of embedded structs without a json name.
+func eachField(t reflect.Type, fn func(sf reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous && sf.Tag.Get("json") == "" && deref(sf.Type).Kind() == reflect.Struct {
+			eachField(deref(sf.Type), fn)
+			continue
+		}
+		if sf.IsExported() {
+			fn(sf)
+		}
+	}
+}
+
+// jsonName returns the name of the field in JSON encoding. Empty if the
+// field is skipped.
+func jsonName(sf reflect.StructField) (name string, hasTag bool) {
+	tag, hasTag := sf.Tag.Lookup("json")
+	name, _, _ = strings.Cut(tag, ",")
+	if name == "-" {
+		return "", hasTag
+	}
+	if name == "" {
+		name = sf.Name
+	}
+	return name, hasTag
+}
+
+func tagName(sf reflect.StructField, tag string) string {
+	name, _, _ := strings.Cut(sf.Tag.Get(tag), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func isRequired(sf reflect.StructField) bool {
+	for _, rule := range strings.Split(sf.Tag.Get("validate"), ",") {
+		if strings.TrimSpace(rule) == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+func deref(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func float(f float64) *float64 { return &f }
+
+func intPtr(f float64) *int {
+	n := int(f)
+	return &n
+}
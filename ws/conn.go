@@ -0,0 +1,358 @@
+// Package ws provides WebSocket connections with buffered writes and
+// keepalive, and a Hub for broadcasting messages over named channels.
+//
+//	hub := ws.NewHub(ws.HubOptions{})
+//	r.Get("/ws", hub.Handler(onConnect, func(conn *ws.Conn, msg ws.Message) {
+//		_ = conn.Join(string(msg.Data))
+//	}))
+//
+//	hub.Broadcast("orders", ws.Message{Data: payload})
+package ws
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/httputils"
+	"github.com/spy16/moonshot/log"
+)
+
+// Options controls the behaviour of the connections.
+type Options struct {
+	// SendBuffer is the number of outgoing messages buffered for each
+	// connection. Defaults to 64.
+	SendBuffer int
+
+	// PingInterval is the interval of keepalive pings. Connections not
+	// responding within PongTimeout are closed. Defaults to 30s and 60s.
+	PingInterval time.Duration
+	PongTimeout  time.Duration
+
+	// WriteTimeout is the deadline for writing a single message. Defaults
+	// to 10s.
+	WriteTimeout time.Duration
+
+	// MaxMessageSize is the maximum size of incoming messages in bytes.
+	// Defaults to 64KiB.
+	MaxMessageSize int64
+
+	// CheckOrigin returns true if the request origin is allowed. Defaults
+	// to allowing only the requests with same origin as the host.
+	CheckOrigin func(req *http.Request) bool
+
+	// Subprotocols are the supported protocols in the order of preference.
+	Subprotocols []string
+}
+
+// ErrClosed is returned when sending on a closed connection.
+var ErrClosed = stderrors.New("websocket connection closed")
+
+// Message is a WebSocket data message.
+type Message struct {
+	Binary bool
+	Data   []byte
+}
+
+// Conn is a WebSocket connection. Writes are buffered and performed by a
+// separate goroutine so that Send is safe for concurrent use.
+type Conn struct {
+	id   string
+	ws   *websocket.Conn
+	hub  *Hub
+	opts Options
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	send   chan Message
+
+	mu       sync.Mutex
+	closed   bool
+	closeErr error
+	channels map[string]struct{}
+}
+
+// Upgrade upgrades the request to a WebSocket connection. Run Serve() on
+// the returned connection before returning from the handler. On failure,
+// an error response is already written to the client.
+func Upgrade(wr http.ResponseWriter, req *http.Request, opts Options) (*Conn, error) {
+	opts.setDefaults()
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin:  opts.CheckOrigin,
+		Subprotocols: opts.Subprotocols,
+		Error: func(wr http.ResponseWriter, req *http.Request, status int, reason error) {
+			httputils.Respond(wr, req, status, upgradeError(status, reason))
+		},
+	}
+
+	wsConn, err := upgrader.Upgrade(wr, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	wsConn.SetReadLimit(opts.MaxMessageSize)
+
+	id := newID()
+	ctx, cancel := context.WithCancel(log.InjectFields(req.Context(), log.Fields{"ws_conn": id}))
+	conn := &Conn{
+		id:       id,
+		ws:       wsConn,
+		opts:     opts,
+		ctx:      ctx,
+		cancel:   cancel,
+		send:     make(chan Message, opts.SendBuffer),
+		channels: map[string]struct{}{},
+	}
+
+	go conn.writeLoop(httputils.ShutdownSignal(req.Context()))
+	return conn, nil
+}
+
+// ID returns the unique identifier of the connection.
+func (c *Conn) ID() string { return c.id }
+
+// Context returns the context of the upgrade request (with the identity,
+// request-id, log fields etc.). It is cancelled when the connection is
+// closed.
+func (c *Conn) Context() context.Context { return c.ctx }
+
+// Identity returns the identity of the upgrade request set by the auth
+// middlewares using httputils.WithIdentity().
+func (c *Conn) Identity() (httputils.Identity, bool) {
+	return httputils.IdentityFrom(c.ctx)
+}
+
+// Subprotocol returns the negotiated subprotocol.
+func (c *Conn) Subprotocol() string { return c.ws.Subprotocol() }
+
+// Send queues the message for writing. If the send buffer is full, it
+// blocks until there is space, ctx is cancelled or the connection closes.
+func (c *Conn) Send(ctx context.Context, msg Message) error {
+	select {
+	case <-c.ctx.Done():
+		return ErrClosed
+
+	default:
+	}
+
+	select {
+	case c.send <- msg:
+		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+
+	case <-c.ctx.Done():
+		return ErrClosed
+	}
+}
+
+// SendJSON queues v encoded as JSON for writing. See Send.
+func (c *Conn) SendJSON(ctx context.Context, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Send(ctx, Message{Data: data})
+}
+
+// trySend queues the message without blocking. Connections that cannot
+// keep up are aborted (see abort).
+func (c *Conn) trySend(msg Message) bool {
+	select {
+	case <-c.ctx.Done():
+		return false
+
+	default:
+	}
+
+	select {
+	case c.send <- msg:
+		return true
+
+	default:
+		if c.abort(&websocket.CloseError{Code: websocket.ClosePolicyViolation, Text: "send buffer full"}) {
+			log.Warnf(c.ctx, "closing slow websocket connection (send buffer full)")
+		}
+		return false
+	}
+}
+
+// Serve reads the messages from the client and invokes fn for each of
+// them until the connection is closed. Returns nil if the connection was
+// closed normally.
+func (c *Conn) Serve(fn func(conn *Conn, msg Message)) error {
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(c.opts.PongTimeout))
+	})
+	_ = c.ws.SetReadDeadline(time.Now().Add(c.opts.PongTimeout))
+
+	for {
+		typ, data, err := c.ws.ReadMessage()
+		if err != nil {
+			c.closeConn(err)
+			return c.Err()
+		}
+
+		// any message from the client proves that it is alive.
+		_ = c.ws.SetReadDeadline(time.Now().Add(c.opts.PongTimeout))
+		if fn != nil {
+			fn(c, Message{Binary: typ == websocket.BinaryMessage, Data: data})
+		}
+	}
+}
+
+// Close closes the connection normally.
+func (c *Conn) Close() error {
+	c.CloseWith(websocket.CloseNormalClosure, "")
+	return nil
+}
+
+// CloseWith sends a close message with the code and reason (see RFC 6455,
+// section 7.4) and closes the connection.
+func (c *Conn) CloseWith(code int, reason string) {
+	deadline := time.Now().Add(c.opts.WriteTimeout)
+	_ = c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	c.closeConn(&websocket.CloseError{Code: code, Text: reason})
+}
+
+// Done returns a channel that is closed when the connection is closed.
+func (c *Conn) Done() <-chan struct{} { return c.ctx.Done() }
+
+// Err returns the reason the connection was closed. Returns nil while the
+// connection is open or if it was closed normally.
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeErr
+}
+
+func (c *Conn) writeLoop(shutdown <-chan struct{}) {
+	ticker := time.NewTicker(c.opts.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			// request context is cancelled when the handler returns.
+			c.closeConn(c.ctx.Err())
+			return
+
+		case <-shutdown:
+			c.CloseWith(websocket.CloseGoingAway, "server shutting down")
+			return
+
+		case msg := <-c.send:
+			typ := websocket.TextMessage
+			if msg.Binary {
+				typ = websocket.BinaryMessage
+			}
+			_ = c.ws.SetWriteDeadline(time.Now().Add(c.opts.WriteTimeout))
+			if err := c.ws.WriteMessage(typ, msg.Data); err != nil {
+				c.closeConn(err)
+				return
+			}
+
+		case <-ticker.C:
+			deadline := time.Now().Add(c.opts.WriteTimeout)
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				c.closeConn(err)
+				return
+			}
+		}
+	}
+}
+
+// closeConn closes the underlying connection and removes it from the hub.
+func (c *Conn) closeConn(reason error) {
+	if c.markClosed(reason) {
+		c.cancel()
+		c.release()
+	}
+}
+
+// abort closes the connection without a close frame and without waiting.
+// Writing the close frame (or even closing a TLS connection) can block on
+// the write lock held by a stalled writer. Returns false if the connection
+// was already closed.
+func (c *Conn) abort(reason error) bool {
+	if !c.markClosed(reason) {
+		return false
+	}
+	c.cancel()
+	go c.release()
+	return true
+}
+
+func (c *Conn) markClosed(reason error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return false
+	}
+	c.closed = true
+	if !websocket.IsCloseError(reason, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		c.closeErr = reason
+	}
+	return true
+}
+
+// release closes the underlying connection and removes it from the hub.
+func (c *Conn) release() {
+	_ = c.ws.Close()
+
+	c.mu.Lock()
+	hub := c.hub
+	c.mu.Unlock()
+	if hub != nil {
+		hub.remove(c)
+	}
+}
+
+// upgradeError maps the handshake failure reported by the upgrader to the
+// Error with the matching status.
+func upgradeError(status int, reason error) error {
+	switch status {
+	case http.StatusForbidden:
+		return errors.ErrForbidden.WithMsgf("%s", reason.Error())
+
+	case http.StatusInternalServerError:
+		return errors.ErrInternal.WithCausef("%s", reason.Error())
+
+	default:
+		return errors.ErrInvalid.WithMsgf("%s", reason.Error())
+	}
+}
+
+func (opts *Options) setDefaults() {
+	if opts.SendBuffer <= 0 {
+		opts.SendBuffer = 64
+	}
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = 30 * time.Second
+	}
+	if opts.PongTimeout <= 0 {
+		opts.PongTimeout = 60 * time.Second
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = 10 * time.Second
+	}
+	if opts.MaxMessageSize <= 0 {
+		opts.MaxMessageSize = 64 * 1024
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
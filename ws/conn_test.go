@@ -0,0 +1,65 @@
+package ws_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/ws"
+)
+
+func TestUpgrade_Errors(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		title      string
+		headers    map[string]string
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			title:      "NotWebSocket",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "bad_request",
+		},
+		{
+			title: "OriginNotAllowed",
+			headers: map[string]string{
+				"Connection":            "Upgrade",
+				"Upgrade":               "websocket",
+				"Sec-WebSocket-Version": "13",
+				"Sec-WebSocket-Key":     "dGhlIHNhbXBsZSBub25jZQ==",
+				"Origin":                "https://evil.example.com",
+			},
+			wantStatus: http.StatusForbidden,
+			wantCode:   "forbidden",
+		},
+	}
+
+	h := http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		_, _ = ws.Upgrade(wr, req, ws.Options{})
+	})
+
+	for _, tt := range table {
+		tt := tt
+		t.Run(tt.title, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://app.example.com/ws", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			var body struct {
+				Code string `json:"code"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantCode, body.Code)
+		})
+	}
+}
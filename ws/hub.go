@@ -0,0 +1,257 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/httputils"
+	"github.com/spy16/moonshot/log"
+)
+
+// HubOptions controls the behaviour of the Hub and its connections.
+type HubOptions struct {
+	Options
+
+	// Authorize is invoked before a connection joins a channel. Use
+	// conn.Identity() for access control. Nil allows all.
+	Authorize func(conn *Conn, channel string) error
+}
+
+// Hub tracks the connections and the named channels they have joined for
+// broadcasting. Connections are closed when the server started using
+// httputils.GracefulServe shuts down or when Close() is called.
+type Hub struct {
+	opts HubOptions
+
+	mu       sync.RWMutex
+	closed   bool
+	conns    map[*Conn]struct{}
+	channels map[string]map[*Conn]struct{}
+}
+
+// NewHub returns a new hub.
+func NewHub(opts HubOptions) *Hub {
+	return &Hub{
+		opts:     opts,
+		conns:    map[*Conn]struct{}{},
+		channels: map[string]map[*Conn]struct{}{},
+	}
+}
+
+// Handler returns a handler that upgrades the requests to WebSocket and
+// registers the connections with the hub. onConnect is invoked after the
+// upgrade (e.g., to join channels) and the connection is closed if it
+// returns error. onMessage is invoked for every message from the client.
+// Both are optional.
+func (h *Hub) Handler(onConnect func(conn *Conn) error, onMessage func(conn *Conn, msg Message)) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if h.isClosed() {
+			httputils.Respond(wr, req, http.StatusServiceUnavailable, errors.ErrUnavailable)
+			return
+		}
+
+		conn, err := Upgrade(wr, req, h.opts.Options)
+		if err != nil {
+			log.Debugf(req.Context(), "websocket upgrade failed: %v", err)
+			return
+		}
+
+		if !h.add(conn) {
+			conn.CloseWith(websocket.CloseGoingAway, "server shutting down")
+			return
+		}
+
+		if onConnect != nil {
+			if err := onConnect(conn); err != nil {
+				conn.CloseWith(websocket.ClosePolicyViolation, closeReason(err))
+				return
+			}
+		}
+
+		if err := conn.Serve(onMessage); err != nil {
+			log.Debugf(conn.Context(), "websocket connection closed: %v", err)
+		}
+	})
+}
+
+// Join adds the connection to the channel after checking with Authorize.
+func (c *Conn) Join(channel string) error {
+	if c.hub == nil {
+		return errors.ErrUnsupported.WithCausef("connection is not managed by a hub")
+	}
+	return c.hub.join(c, channel)
+}
+
+// Leave removes the connection from the channel.
+func (c *Conn) Leave(channel string) {
+	if c.hub != nil {
+		c.hub.leave(c, channel)
+	}
+}
+
+// Channels returns the channels joined by the connection.
+func (c *Conn) Channels() []string {
+	if c.hub == nil {
+		return nil
+	}
+
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+
+	var res []string
+	for ch := range c.channels {
+		res = append(res, ch)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Broadcast queues the message on all the connections in the channel and
+// returns the number of connections it was queued on. Connections with
+// full send buffer are closed in the background instead of blocking the
+// broadcast.
+func (h *Hub) Broadcast(channel string, msg Message) int {
+	h.mu.RLock()
+	members := make([]*Conn, 0, len(h.channels[channel]))
+	for conn := range h.channels[channel] {
+		members = append(members, conn)
+	}
+	h.mu.RUnlock()
+
+	sent := 0
+	for _, conn := range members {
+		if conn.trySend(msg) {
+			sent++
+		}
+	}
+	return sent
+}
+
+// BroadcastJSON broadcasts v encoded as JSON. See Broadcast.
+func (h *Hub) BroadcastJSON(channel string, v interface{}) (int, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	return h.Broadcast(channel, Message{Data: data}), nil
+}
+
+// Count returns the number of connections in the channel.
+func (h *Hub) Count(channel string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.channels[channel])
+}
+
+// Close closes all the connections and rejects new ones.
+func (h *Hub) Close() error {
+	h.mu.Lock()
+	h.closed = true
+	conns := make([]*Conn, 0, len(h.conns))
+	for conn := range h.conns {
+		conns = append(conns, conn)
+	}
+	h.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.CloseWith(websocket.CloseGoingAway, "server shutting down")
+	}
+	return nil
+}
+
+func (h *Hub) add(conn *Conn) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return false
+	}
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.closed {
+		return false
+	}
+	conn.hub = h
+	h.conns[conn] = struct{}{}
+	return true
+}
+
+func (h *Hub) remove(conn *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.conns, conn)
+	for ch := range conn.channels {
+		h.removeMember(ch, conn)
+	}
+	conn.channels = nil
+}
+
+func (h *Hub) join(conn *Conn, channel string) error {
+	if h.opts.Authorize != nil {
+		if err := h.opts.Authorize(conn, channel); err != nil {
+			return err
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, found := h.conns[conn]; !found {
+		return ErrClosed
+	}
+	if h.channels[channel] == nil {
+		h.channels[channel] = map[*Conn]struct{}{}
+	}
+	h.channels[channel][conn] = struct{}{}
+	conn.channels[channel] = struct{}{}
+	return nil
+}
+
+func (h *Hub) leave(conn *Conn, channel string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(conn.channels, channel)
+	h.removeMember(channel, conn)
+}
+
+func (h *Hub) removeMember(channel string, conn *Conn) {
+	members := h.channels[channel]
+	delete(members, conn)
+	if len(members) == 0 {
+		delete(h.channels, channel)
+	}
+}
+
+func (h *Hub) isClosed() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.closed
+}
+
+// closeReason returns the error message truncated to fit in the close
+// frame (123 bytes). Reason must be valid UTF-8, so it is truncated at a
+// rune boundary.
+func closeReason(err error) string {
+	const maxLen = 123
+
+	reason := strings.ToValidUTF8(errors.E(err).Message, "")
+	if len(reason) <= maxLen {
+		return reason
+	}
+
+	n := maxLen
+	for n > 0 && !utf8.RuneStart(reason[n]) {
+		n--
+	}
+	return reason[:n]
+}
@@ -0,0 +1,236 @@
+package ws_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spy16/moonshot/errors"
+	"github.com/spy16/moonshot/httputils"
+	"github.com/spy16/moonshot/ws"
+)
+
+func TestHub(t *testing.T) {
+	t.Parallel()
+
+	hub := ws.NewHub(ws.HubOptions{
+		Authorize: func(conn *ws.Conn, channel string) error {
+			id, _ := conn.Identity()
+			if strings.HasPrefix(channel, "private:") && channel != "private:"+id.Subject {
+				return errors.ErrForbidden
+			}
+			return nil
+		},
+	})
+
+	joined := make(chan string, 10)
+	h := hub.Handler(nil, func(conn *ws.Conn, msg ws.Message) {
+		if err := conn.Join(string(msg.Data)); err != nil {
+			_ = conn.SendJSON(conn.Context(), errors.E(err))
+			return
+		}
+		joined <- string(msg.Data)
+	})
+	srv := httptest.NewServer(withUser(h))
+	defer srv.Close()
+
+	alice := dial(t, srv.URL, "alice")
+	bob := dial(t, srv.URL, "bob")
+
+	require.NoError(t, alice.WriteMessage(websocket.TextMessage, []byte("news")))
+	require.NoError(t, bob.WriteMessage(websocket.TextMessage, []byte("news")))
+	require.NoError(t, alice.WriteMessage(websocket.TextMessage, []byte("private:alice")))
+	require.NoError(t, bob.WriteMessage(websocket.TextMessage, []byte("private:alice")))
+	for i := 0; i < 3; i++ {
+		<-joined
+	}
+
+	assert.Equal(t, `{"code":"forbidden","message":"You are not authorised for the requested action"}`, readText(t, bob))
+	assert.Equal(t, 2, hub.Count("news"))
+	assert.Equal(t, 1, hub.Count("private:alice"))
+
+	n, err := hub.BroadcastJSON("news", map[string]string{"title": "hello"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, `{"title":"hello"}`, readText(t, alice))
+	assert.Equal(t, `{"title":"hello"}`, readText(t, bob))
+
+	assert.Equal(t, 1, hub.Broadcast("private:alice", ws.Message{Data: []byte("secret")}))
+	assert.Equal(t, "secret", readText(t, alice))
+
+	require.NoError(t, bob.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")))
+	assert.Eventually(t, func() bool { return hub.Count("news") == 1 }, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, hub.Close())
+	_, _, err = alice.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "got %v", err)
+	assert.Equal(t, 0, hub.Count("news"))
+}
+
+func TestHub_SlowConsumer(t *testing.T) {
+	t.Parallel()
+
+	hub := ws.NewHub(ws.HubOptions{Options: ws.Options{SendBuffer: 1}})
+	connected := make(chan *ws.Conn, 1)
+	srv := httptest.NewServer(hub.Handler(func(conn *ws.Conn) error {
+		connected <- conn
+		return conn.Join("feed")
+	}, nil))
+	defer srv.Close()
+
+	client := dial(t, srv.URL, "")
+	conn := <-connected
+
+	big := ws.Message{Data: make([]byte, 1<<20)}
+	for i := 0; i < 100 && hub.Count("feed") > 0; i++ {
+		hub.Broadcast("feed", big)
+	}
+
+	<-conn.Done()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, conn.Err(), &closeErr)
+	assert.Equal(t, websocket.ClosePolicyViolation, closeErr.Code)
+	assert.Eventually(t, func() bool { return hub.Count("feed") == 0 }, time.Second, 5*time.Millisecond)
+	_ = client.Close()
+}
+
+func TestHub_StalledReader(t *testing.T) {
+	t.Parallel()
+
+	hub := ws.NewHub(ws.HubOptions{Options: ws.Options{SendBuffer: 4, WriteTimeout: 5 * time.Second}})
+	connected := make(chan *ws.Conn, 1)
+	srv := httptest.NewServer(hub.Handler(func(conn *ws.Conn) error {
+		connected <- conn
+		return conn.Join("feed")
+	}, nil))
+	defer srv.Close()
+
+	_ = dial(t, srv.URL, "") // never reads.
+	conn := <-connected
+	require.Eventually(t, func() bool { return hub.Count("feed") == 1 }, time.Second, 5*time.Millisecond)
+
+	// socket buffers fill up well before the send buffer, so the writer
+	// is stuck in a write by the time the send buffer is full.
+	big := ws.Message{Data: make([]byte, 8<<20)}
+	for i := 0; i < 200 && hub.Count("feed") > 0; i++ {
+		start := time.Now()
+		hub.Broadcast("feed", big)
+		require.Less(t, time.Since(start), time.Second, "broadcast must not block on a stalled reader")
+		time.Sleep(5 * time.Millisecond) // let the writer pick it up.
+	}
+
+	select {
+	case <-conn.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("slow connection was not closed")
+	}
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, conn.Err(), &closeErr)
+	assert.Equal(t, websocket.ClosePolicyViolation, closeErr.Code)
+	assert.Eventually(t, func() bool { return hub.Count("feed") == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestHub_Rejected(t *testing.T) {
+	t.Parallel()
+
+	hub := ws.NewHub(ws.HubOptions{})
+	srv := httptest.NewServer(hub.Handler(func(conn *ws.Conn) error {
+		if _, ok := conn.Identity(); !ok {
+			return errors.ErrForbidden.WithMsgf("login required")
+		}
+		return nil
+	}, nil))
+	defer srv.Close()
+
+	client := dial(t, srv.URL, "")
+	_, _, err := client.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.ClosePolicyViolation, closeErr.Code)
+	assert.Equal(t, "login required", closeErr.Text)
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestHub_RejectedLongReason(t *testing.T) {
+	t.Parallel()
+
+	msg := strings.Repeat("é", 80) // 160 bytes, 123 splits a rune.
+	hub := ws.NewHub(ws.HubOptions{})
+	srv := httptest.NewServer(hub.Handler(func(conn *ws.Conn) error {
+		return errors.ErrForbidden.WithMsgf(msg)
+	}, nil))
+	defer srv.Close()
+
+	client := dial(t, srv.URL, "")
+	_, _, err := client.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.True(t, utf8.ValidString(closeErr.Text))
+	assert.Equal(t, strings.Repeat("é", 61), closeErr.Text)
+}
+
+func TestHub_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+
+	hub := ws.NewHub(ws.HubOptions{})
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- httputils.GracefulServe(ctx, 10*time.Second, lis, hub.Handler(nil, nil)) }()
+
+	var client *websocket.Conn
+	require.Eventually(t, func() bool {
+		client, _, err = websocket.DefaultDialer.Dial("ws://"+addr, nil)
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	_, _, err = client.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "got %v", err)
+	assert.NoError(t, <-served)
+}
+
+func withUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		if user := req.URL.Query().Get("user"); user != "" {
+			req = req.WithContext(httputils.WithIdentity(req.Context(), httputils.Identity{Subject: user}))
+		}
+		next.ServeHTTP(wr, req)
+	})
+}
+
+func dial(t *testing.T, url, user string) *websocket.Conn {
+	t.Helper()
+
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http")+"?user="+user, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func readText(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	typ, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.TextMessage, typ)
+	return string(data)
+}